@@ -0,0 +1,167 @@
+package readability
+
+import (
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"strings"
+)
+
+// BlockType is the kind of a content block.
+type BlockType string
+
+// The kinds of content block.
+const (
+	BlockParagraph BlockType = "paragraph"
+	BlockHeading   BlockType = "heading"
+	BlockImage     BlockType = "image"
+	BlockList      BlockType = "list"
+	BlockQuote     BlockType = "quote"
+	BlockCode      BlockType = "code"
+)
+
+// Block is a single block of the article content, so the content can be
+// rendered natively without HTML engine. Type decides which fields are used:
+//   - paragraph, quote and code use Text. Code keeps its whitespace as is.
+//   - heading uses Text and Level, from 1 to 6.
+//   - image uses URL and Alt.
+//   - list uses Items, and Ordered if it's a numbered list.
+type Block struct {
+	Type    BlockType
+	Text    string
+	Level   int
+	URL     string
+	Alt     string
+	Items   []string
+	Ordered bool
+}
+
+// Convert the content into blocks. Inline elements are merged into paragraph,
+// except image which always becomes its own block. Elements that don't have
+// their own kind, e.g. div or table, are flattened into their children.
+func (r *readability) getBlocks(content *goquery.Selection) []Block {
+	var blocks []Block
+	for _, n := range content.Nodes {
+		blocks = append(blocks, r.blockChildren(n)...)
+	}
+
+	if !r.opts.KeepInvisibleChars {
+		for i := range blocks {
+			blocks[i].Text = removeInvisibleChars(blocks[i].Text)
+			blocks[i].Alt = removeInvisibleChars(blocks[i].Alt)
+			for j := range blocks[i].Items {
+				blocks[i].Items[j] = removeInvisibleChars(blocks[i].Items[j])
+			}
+		}
+	}
+
+	return blocks
+}
+
+// Convert all children of the node into blocks. Consecutive text and inline
+// elements are collected as one paragraph.
+func (r *readability) blockChildren(n *html.Node) []Block {
+	var blocks []Block
+	paragraph := ""
+	flush := func() {
+		if text := NormalizeText(paragraph); text != "" {
+			blocks = append(blocks, Block{Type: BlockParagraph, Text: text})
+		}
+		paragraph = ""
+	}
+
+	var inline func(*html.Node)
+	inline = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			paragraph += n.Data
+		case n.DataAtom == atom.Img:
+			flush()
+			if img := r.imageBlock(n); img != nil {
+				blocks = append(blocks, *img)
+			}
+		case n.DataAtom == atom.Br:
+			paragraph += " "
+		case n.Type == html.ElementNode:
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				inline(c)
+			}
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if isPhrasingContent(c) {
+			inline(c)
+			continue
+		}
+
+		flush()
+		blocks = append(blocks, r.blockNode(c)...)
+	}
+	flush()
+
+	return blocks
+}
+
+// Convert a single block element into blocks.
+func (r *readability) blockNode(n *html.Node) []Block {
+	if n.Type != html.ElementNode {
+		return nil
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		text := NormalizeText(nodeText(n))
+		if text == "" {
+			return nil
+		}
+		return []Block{{Type: BlockHeading, Text: text, Level: int(n.Data[1] - '0')}}
+
+	case atom.Ul, atom.Ol:
+		var items []string
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.DataAtom != atom.Li {
+				continue
+			}
+			if text := NormalizeText(nodeText(c)); text != "" {
+				items = append(items, text)
+			}
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		return []Block{{Type: BlockList, Items: items, Ordered: n.DataAtom == atom.Ol}}
+
+	case atom.Blockquote:
+		// Paragraphs inside quote are kept as separate lines
+		var lines []string
+		for _, block := range r.blockChildren(n) {
+			if block.Text != "" {
+				lines = append(lines, block.Text)
+			}
+		}
+		if len(lines) == 0 {
+			return nil
+		}
+		return []Block{{Type: BlockQuote, Text: strings.Join(lines, "\n\n")}}
+
+	case atom.Pre:
+		text := strings.Trim(nodeText(n), "\n")
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		return []Block{{Type: BlockCode, Text: text}}
+	}
+
+	return r.blockChildren(n)
+}
+
+// Convert the image into block. Returns nil if the image doesn't have URL.
+func (r *readability) imageBlock(n *html.Node) *Block {
+	src := strings.TrimSpace(attrOr(n, "src", ""))
+	if src == "" {
+		return nil
+	}
+
+	return &Block{Type: BlockImage, URL: src, Alt: NormalizeText(attrOr(n, "alt", ""))}
+}
@@ -0,0 +1,53 @@
+package readability
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrEmptyHTML is returned when the page doesn't have any HTML.
+	ErrEmptyHTML = errors.New("HTML is empty")
+
+	// ErrNoContent is returned when there are no readable content found in
+	// the page. The article is still returned along with this error, so its
+	// metadata can still be used.
+	ErrNoContent = errors.New("no readable content found")
+
+	// ErrContentTooShort is returned when the content found is shorter than
+	// the minimum length in options. Like ErrNoContent, the article is still
+	// returned along with this error.
+	ErrContentTooShort = errors.New("content is too short")
+
+	// ErrTooManyElements is returned when the page has more elements than the
+	// maximum number allowed in options.
+	ErrTooManyElements = errors.New("too many elements in page")
+
+	// ErrTooManyRedirects is returned when the page redirects more times than
+	// the maximum number allowed in options. It's wrapped in *url.Error, so use
+	// errors.Is to check it.
+	ErrTooManyRedirects = errors.New("too many redirects")
+
+	// ErrUnsupportedEncoding is returned when the page is compressed using
+	// content encoding that can't be decoded, e.g. compress.
+	ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+
+	// ErrHTTPStatus is returned when the page responded with non-2xx status.
+	// Use errors.As with *StatusError to get the status code.
+	ErrHTTPStatus = errors.New("unexpected HTTP status")
+)
+
+// StatusError is the error returned when the page responded with non-2xx status.
+type StatusError struct {
+	StatusCode int
+}
+
+func (err *StatusError) Error() string {
+	return fmt.Sprintf("%v: %d %s", ErrHTTPStatus, err.StatusCode, http.StatusText(err.StatusCode))
+}
+
+// Unwrap makes StatusError matched with ErrHTTPStatus by errors.Is.
+func (err *StatusError) Unwrap() error {
+	return ErrHTTPStatus
+}
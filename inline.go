@@ -0,0 +1,256 @@
+package readability
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/PuerkitoBio/goquery"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	nurl "net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// errPrivateHost is returned when the image is in loopback, private or
+// link-local address.
+var errPrivateHost = errors.New("private host is not allowed")
+
+// publicTransports caches the copy of each transport that only connects to
+// public addresses, so the connections to image hosts are reused by every
+// page instead of leaking.
+var publicTransports sync.Map
+
+// inlineImage is an image inside the content which will be inlined, along
+// with the result of fetching it.
+type inlineImage struct {
+	url     string
+	nodes   []*goquery.Selection
+	dataURI string
+	size    int64
+}
+
+// Fetch the images inside the content and replace their URL with data URI.
+// Each image URL is only fetched once, even if it's used several times. The
+// images are fetched concurrently in document order, and the total size
+// limit is applied in document order as well.
+func (r *readability) inlineImages(ctx context.Context, content *goquery.Selection) {
+	maxSize := r.opts.MaxInlineImageSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxInlineImageSize
+	}
+
+	maxTotalSize := r.opts.MaxInlineImagesSize
+	if maxTotalSize <= 0 {
+		maxTotalSize = defaultMaxInlineImagesSize
+	}
+
+	concurrency := r.opts.InlineImageConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultInlineImageConcurrency
+	}
+
+	timeout := r.opts.InlineImageTimeout
+	if timeout <= 0 {
+		timeout = defaultInlineImageTimeout
+	}
+
+	client := r.opts.HTTPClient
+	if client == nil {
+		client = newHTTPClient(r.opts)
+	}
+
+	// Images in private address are blocked when they're connected, which
+	// covers the redirects as well. If the transport can't be checked, none
+	// of the images are fetched.
+	if !r.opts.InlinePrivateImages {
+		if client = publicClient(client); client == nil {
+			return
+		}
+	}
+
+	// Group the images by their URL. Image which is not fetched over HTTP,
+	// e.g. the one that's already a data URI, is left as it is.
+	var images []*inlineImage
+	imageByURL := make(map[string]*inlineImage)
+	content.Find("img").Each(func(_ int, img *goquery.Selection) {
+		src := strings.TrimSpace(img.AttrOr("src", ""))
+		parsedURL, err := nurl.Parse(src)
+		if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+			return
+		}
+
+		image, exist := imageByURL[src]
+		if !exist {
+			image = &inlineImage{url: src}
+			imageByURL[src] = image
+			images = append(images, image)
+		}
+		image.nodes = append(image.nodes, img)
+	})
+
+	// Stop fetching once the fetched images fill the total size, since
+	// the rest won't be inlined anyway
+	var fetchedSize int64
+	var wg sync.WaitGroup
+	queue := make(chan *inlineImage)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for image := range queue {
+				if atomic.LoadInt64(&fetchedSize) >= maxTotalSize {
+					continue
+				}
+
+				image.dataURI, image.size = r.fetchDataURI(ctx, client, image.url, maxSize, timeout)
+				atomic.AddInt64(&fetchedSize, image.size)
+			}
+		}()
+	}
+
+	for _, image := range images {
+		queue <- image
+	}
+	close(queue)
+	wg.Wait()
+
+	var totalSize int64
+	for _, image := range images {
+		if image.dataURI == "" || totalSize+image.size > maxTotalSize {
+			continue
+		}
+
+		// The responsive sources are removed, otherwise browser will fetch
+		// them instead of using the data URI
+		totalSize += image.size
+		for _, img := range image.nodes {
+			img.SetAttr("src", image.dataURI)
+			img.RemoveAttr("srcset")
+			img.RemoveAttr("sizes")
+		}
+	}
+}
+
+// Fetch the image in the URL and return it as base64 data URI, along with
+// its size in bytes. Returns empty string if the image can't be fetched
+// within the timeout, it's bigger than the maximum size, or it's not a safe
+// image type.
+func (r *readability) fetchDataURI(ctx context.Context, client *http.Client, url string, maxSize int64, timeout time.Duration) (string, int64) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", 0
+	}
+
+	for key, values := range r.opts.Header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	if r.opts.UserAgent != "" {
+		req.Header.Set("User-Agent", r.opts.UserAgent)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", 0
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || resp.ContentLength > maxSize {
+		return "", 0
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil || len(data) == 0 || int64(len(data)) > maxSize {
+		return "", 0
+	}
+
+	// The type is sniffed from the data, since servers often send images
+	// as generic binary. The header is only used for types that can't be
+	// sniffed, e.g. AVIF.
+	mimeType := http.DetectContentType(data)
+	if mimeType == "application/octet-stream" {
+		mimeType, _, _ = mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	}
+
+	dataURI := "data:" + strings.ToLower(mimeType) + ";base64," + base64.StdEncoding.EncodeToString(data)
+	if !dataImageURL.MatchString(dataURI) {
+		return "", 0
+	}
+
+	return dataURI, int64(len(data))
+}
+
+// publicClient returns a copy of client that refuses to connect to private
+// address. The address is checked after it's dialed instead of resolving
+// the host beforehand, so it can't be bypassed by DNS rebinding, and every
+// redirect is checked as well. When the transport uses a proxy, the proxy
+// address is the one checked. Returns nil if the client doesn't use
+// *http.Transport, since its connections can't be checked.
+func publicClient(client *http.Client) *http.Client {
+	transport, ok := client.Transport.(*http.Transport)
+	if client.Transport == nil {
+		transport, ok = http.DefaultTransport.(*http.Transport)
+	}
+
+	if !ok {
+		return nil
+	}
+
+	cached, exist := publicTransports.Load(transport)
+	if !exist {
+		dial := transport.DialContext
+		if dial == nil {
+			dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+			dial = dialer.DialContext
+		}
+
+		publicTransport := transport.Clone()
+		publicTransport.DialContext = publicDial(dial)
+		if transport.DialTLSContext != nil {
+			publicTransport.DialTLSContext = publicDial(transport.DialTLSContext)
+		}
+
+		cached, _ = publicTransports.LoadOrStore(transport, publicTransport)
+	}
+
+	copied := *client
+	copied.Transport = cached.(*http.Transport)
+	return &copied
+}
+
+// publicDial wraps the dial function, so the connection is closed before
+// anything is sent if it's connected to private address.
+func publicDial(dial func(context.Context, string, string) (net.Conn, error)) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+		if ip := net.ParseIP(host); err != nil || ip == nil || isPrivateIP(ip) {
+			conn.Close()
+			return nil, errPrivateHost
+		}
+
+		return conn, nil
+	}
+}
+
+// isPrivateIP checks if the IP is loopback, private, link-local or
+// unspecified address.
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast()
+}
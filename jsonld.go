@@ -0,0 +1,164 @@
+package readability
+
+import (
+	"encoding/json"
+	"github.com/PuerkitoBio/goquery"
+	"regexp"
+	"strings"
+)
+
+var (
+	jsonLDArticleTypes = regexp.MustCompile(`^(Article|AdvertiserContentArticle|NewsArticle|AnalysisNewsArticle|AskPublicQuestionArticle|BackgroundNewsArticle|OpinionNewsArticle|ReportageNewsArticle|ReviewNewsArticle|Report|SatiricalArticle|ScholarlyArticle|MedicalScholarlyArticle|SocialMediaPosting|BlogPosting|LiveBlogPosting|DiscussionForumPosting|TechArticle|APIReference)$`)
+	cdataWrapper       = regexp.MustCompile(`^\s*<!\[CDATA\[|\]\]>\s*$`)
+)
+
+// jsonLD is the schema.org metadata of an article, which embedded
+// in the page as JSON-LD script.
+type jsonLD struct {
+	Headline      string
+	Authors       []string
+	Image         string
+	Description   string
+	Publisher     string
+	DatePublished string
+
+	// Paywalled is true when the article declares that it isn't accessible
+	// for free, i.e. part of its content is behind paywall.
+	Paywalled bool
+}
+
+// Fetch the schema.org metadata from JSON-LD script. Only the first object
+// that has an article type will be used.
+func (r *readability) getJSONLD(doc *goquery.Document) jsonLD {
+	result := jsonLD{}
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(_ int, script *goquery.Selection) bool {
+		// Some sites wrap their JSON-LD inside CDATA, so remove it first
+		content := cdataWrapper.ReplaceAllString(script.Text(), "")
+
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(content), &parsed); err != nil {
+			return true
+		}
+
+		article := findJSONLDArticle(parsed)
+		if article == nil {
+			return true
+		}
+
+		result.Headline = jsonLDString(article["headline"])
+		if result.Headline == "" {
+			result.Headline = jsonLDString(article["name"])
+		}
+
+		result.Authors = jsonLDNames(article["author"])
+		result.Image = jsonLDURL(article["image"])
+		result.Description = jsonLDString(article["description"])
+		result.Publisher = strings.Join(jsonLDNames(article["publisher"]), ", ")
+		result.DatePublished = jsonLDString(article["datePublished"])
+		result.Paywalled = jsonLDFalse(article["isAccessibleForFree"])
+		return false
+	})
+
+	return result
+}
+
+// findJSONLDArticle looks for the first object with article type,
+// either directly, inside an array or inside a @graph wrapper.
+func findJSONLDArticle(value interface{}) map[string]interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if article := findJSONLDArticle(item); article != nil {
+				return article
+			}
+		}
+	case map[string]interface{}:
+		if isJSONLDArticle(v["@type"]) {
+			return v
+		}
+
+		if graph, ok := v["@graph"]; ok {
+			return findJSONLDArticle(graph)
+		}
+	}
+
+	return nil
+}
+
+// isJSONLDArticle checks if the @type value, which could be a string or
+// an array of string, is one of the article types.
+func isJSONLDArticle(value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return jsonLDArticleTypes.MatchString(v)
+	case []interface{}:
+		for _, item := range v {
+			if isJSONLDArticle(item) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// jsonLDString returns the value as trimmed string, or empty string
+// if it's not a string.
+func jsonLDString(value interface{}) string {
+	str, _ := value.(string)
+	return strings.TrimSpace(str)
+}
+
+// jsonLDFalse checks if the value is false, which could be a boolean or
+// a string since some sites write it as "False".
+func jsonLDFalse(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return !v
+	case string:
+		return strings.EqualFold(strings.TrimSpace(v), "false")
+	}
+
+	return false
+}
+
+// jsonLDNames returns the names of person or organization. The value could be
+// a plain string, an object with name property, or an array of both.
+func jsonLDNames(value interface{}) []string {
+	names := []string{}
+	switch v := value.(type) {
+	case string:
+		if name := strings.TrimSpace(v); name != "" {
+			names = append(names, name)
+		}
+	case map[string]interface{}:
+		if name := jsonLDString(v["name"]); name != "" {
+			names = append(names, name)
+		}
+	case []interface{}:
+		for _, item := range v {
+			names = append(names, jsonLDNames(item)...)
+		}
+	}
+
+	return names
+}
+
+// jsonLDURL returns the first URL of an image. The value could be a plain
+// string, an ImageObject with url property, or an array of both.
+func jsonLDURL(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case map[string]interface{}:
+		return jsonLDString(v["url"])
+	case []interface{}:
+		for _, item := range v {
+			if url := jsonLDURL(item); url != "" {
+				return url
+			}
+		}
+	}
+
+	return ""
+}
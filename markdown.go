@@ -0,0 +1,210 @@
+package readability
+
+import (
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"regexp"
+	"strings"
+)
+
+var (
+	mdSpaces        = regexp.MustCompile(`\s+`)
+	mdBlankLines    = regexp.MustCompile(`[ \t]*\n(?:[ \t]*\n)+`)
+	mdBlockMarker   = regexp.MustCompile(`^(\s*)([#>+=-])`)
+	mdOrderedMarker = regexp.MustCompile(`^(\s*\d+)([.)])`)
+	mdBackticks     = regexp.MustCompile("`+")
+	mdEscaper       = strings.NewReplacer(`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`)
+	mdURLEscaper    = strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29", "<", "%3C", ">", "%3E")
+)
+
+// Convert the content into Markdown. The links and images are expected
+// to be absolute already, since this is done after fixRelativeURIs.
+func (r *readability) getMarkdownContent(content *goquery.Selection) string {
+	var buf strings.Builder
+	for _, n := range content.Nodes {
+		r.markdownChildren(&buf, n)
+	}
+
+	markdown := mdBlankLines.ReplaceAllString(buf.String(), "\n\n")
+	return strings.TrimSpace(markdown)
+}
+
+// Convert all children of the node into Markdown.
+func (r *readability) markdownChildren(w *strings.Builder, n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.markdownNode(w, c)
+	}
+}
+
+// Convert all children of the node into Markdown, and return it without
+// the surrounding whitespace. It's used when the Markdown of children
+// needs to be processed further, e.g. to be prefixed or indented.
+func (r *readability) markdownText(n *html.Node) string {
+	var buf strings.Builder
+	r.markdownChildren(&buf, n)
+	return strings.TrimSpace(buf.String())
+}
+
+// Convert a single node and its children into Markdown.
+func (r *readability) markdownNode(w *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.WriteString(escapeMarkdown(mdSpaces.ReplaceAllString(n.Data, " ")))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level := int(n.Data[1] - '0')
+		if text := r.markdownText(n); text != "" {
+			w.WriteString("\n\n" + strings.Repeat("#", level) + " " + text + "\n\n")
+		}
+
+	case "p", "div", "section", "article", "header", "footer", "main",
+		"figure", "figcaption", "dl", "dt", "dd", "details":
+		w.WriteString("\n\n" + r.markdownText(n) + "\n\n")
+
+	// Markdown doesn't have collapsible block, so the summary of details is
+	// written as bold paragraph above its content
+	case "summary":
+		if text := r.markdownText(n); text != "" {
+			w.WriteString("\n\n**" + text + "**\n\n")
+		}
+
+	case "table":
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && c.Data == "caption" {
+				w.WriteString("\n\n" + NormalizeText(nodeText(c)))
+			}
+		}
+		w.WriteString("\n\n" + r.getTableText(n) + "\n\n")
+
+	case "br":
+		w.WriteString("  \n")
+
+	case "hr":
+		w.WriteString("\n\n---\n\n")
+
+	case "strong", "b":
+		r.markdownWrap(w, n, "**")
+
+	case "em", "i":
+		r.markdownWrap(w, n, "_")
+
+	case "del", "s", "strike":
+		r.markdownWrap(w, n, "~~")
+
+	// The code is wrapped with more backticks than the ones inside it, and
+	// padded with space if it starts or ends with backtick
+	case "code":
+		text := nodeText(n)
+		if text == "" {
+			return
+		}
+
+		fence := markdownFence(text, 1)
+		if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+			text = " " + text + " "
+		}
+		w.WriteString(fence + text + fence)
+
+	case "pre":
+		code := strings.Trim(nodeText(n), "\n")
+		fence := markdownFence(code, 3)
+		w.WriteString("\n\n" + fence + "\n" + code + "\n" + fence + "\n\n")
+
+	case "a":
+		text := r.markdownText(n)
+		href := strings.TrimSpace(attrOr(n, "href", ""))
+		if href == "" || text == "" {
+			w.WriteString(text)
+			return
+		}
+		w.WriteString("[" + text + "](" + mdURLEscaper.Replace(href) + ")")
+
+	case "img":
+		src := strings.TrimSpace(attrOr(n, "src", ""))
+		if src == "" {
+			return
+		}
+		alt := mdEscaper.Replace(NormalizeText(attrOr(n, "alt", "")))
+		w.WriteString("![" + alt + "](" + mdURLEscaper.Replace(src) + ")")
+
+	case "ul", "ol":
+		w.WriteString("\n\n" + r.markdownList(n) + "\n\n")
+
+	case "blockquote":
+		text := strings.TrimSpace(mdBlankLines.ReplaceAllString(r.markdownText(n), "\n\n"))
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+line, " ")
+		}
+		w.WriteString("\n\n" + strings.Join(lines, "\n") + "\n\n")
+
+	default:
+		r.markdownChildren(w, n)
+	}
+}
+
+// Wrap the Markdown of node's children with the marker, e.g. ** for bold.
+func (r *readability) markdownWrap(w *strings.Builder, n *html.Node, marker string) {
+	if text := r.markdownText(n); text != "" {
+		w.WriteString(marker + text + marker)
+	}
+}
+
+// Convert list items into Markdown. Lines after the first one in each item
+// are indented, so nested lists will be indented as well.
+func (r *readability) markdownList(list *html.Node) string {
+	items := []string{}
+	for c := list.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.Data != "li" {
+			continue
+		}
+
+		marker := "- "
+		if list.Data == "ol" {
+			marker = fmt.Sprintf("%d. ", len(items)+1)
+		}
+
+		text := strings.TrimSpace(mdBlankLines.ReplaceAllString(r.markdownText(c), "\n"))
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			if i == 0 {
+				lines[i] = marker + line
+			} else if line != "" {
+				lines[i] = strings.Repeat(" ", len(marker)) + line
+			}
+		}
+
+		items = append(items, strings.Join(lines, "\n"))
+	}
+
+	return strings.Join(items, "\n")
+}
+
+// Escape the characters in text that would be read as Markdown syntax. The
+// block markers like "#", "-" and "1." are only escaped at the beginning,
+// since that's the only place where they can start a block.
+func escapeMarkdown(text string) string {
+	text = mdEscaper.Replace(text)
+	text = mdBlockMarker.ReplaceAllString(text, `$1\$2`)
+	return mdOrderedMarker.ReplaceAllString(text, `$1\$2`)
+}
+
+// Get the fence for code, which is longer than the longest run of backticks
+// inside the code, but at least as long as minLength.
+func markdownFence(code string, minLength int) string {
+	length := minLength
+	for _, backticks := range mdBackticks.FindAllString(code, -1) {
+		if len(backticks) >= length {
+			length = len(backticks) + 1
+		}
+	}
+
+	return strings.Repeat("`", length)
+}
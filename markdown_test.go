@@ -0,0 +1,62 @@
+package readability
+
+import (
+	"github.com/PuerkitoBio/goquery"
+	"strings"
+	"testing"
+)
+
+func TestGetMarkdownContent(t *testing.T) {
+	html := `<div>
+		<h2>Getting <em>started</em></h2>
+		<p>Read the <a href="https://example.com/docs">docs</a> and <strong>install</strong> it.</p>
+		<ul><li>First</li><li>Second<ol><li>Nested</li></ol></li></ul>
+		<blockquote><p>Simple is better.</p><p>Really.</p></blockquote>
+		<pre><code>func main() {
+	fmt.Println("hi")
+}</code></pre>
+		<p><img src="https://example.com/a.png" alt="A picture"></p>
+	</div>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := readability{}
+	markdown := r.getMarkdownContent(doc.Find("div").First())
+	expected := "## Getting _started_\n\n" +
+		"Read the [docs](https://example.com/docs) and **install** it.\n\n" +
+		"- First\n- Second\n  1. Nested\n\n" +
+		"> Simple is better.\n>\n> Really.\n\n" +
+		"```\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n```\n\n" +
+		"![A picture](https://example.com/a.png)"
+
+	if markdown != expected {
+		t.Errorf("unexpected markdown:\n%s", markdown)
+	}
+}
+
+func TestMarkdownEscaping(t *testing.T) {
+	tests := map[string]string{
+		`<p>Use *args and **kwargs, not __init__ or [x].</p>`:                   `Use \*args and \*\*kwargs, not \_\_init\_\_ or \[x\].`,
+		`<p># Not a heading</p>`:                                                `\# Not a heading`,
+		`<p>- not a list</p><p>2018. A good year.</p>`:                          "\\- not a list\n\n2018\\. A good year.",
+		`<p>Run <code>echo ` + "`date`" + `</code> now.</p>`:                    "Run `` echo `date` `` now.",
+		`<pre>` + "```go\nfmt.Println(\"hi\")\n```" + `</pre>`:                  "````\n```go\nfmt.Println(\"hi\")\n```\n````",
+		`<p><a href="https://example.com/a (1).html">Docs</a></p>`:              `[Docs](https://example.com/a%20%281%29.html)`,
+		`<p><img src="https://example.com/my image.png" alt="A [big] map"></p>`: `![A \[big\] map](https://example.com/my%20image.png)`,
+	}
+
+	for html, expected := range tests {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader("<div>" + html + "</div>"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := readability{}
+		if markdown := r.getMarkdownContent(doc.Find("div").First()); markdown != expected {
+			t.Errorf("%q: expected %q, got %q", html, expected, markdown)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package readability
+
+import (
+	"net/http"
+	"regexp"
+	"time"
+)
+
+const (
+	// defaultImageReadTime is the time needed to see an image in article.
+	defaultImageReadTime = 12 * time.Second
+
+	// defaultRetryBackoff is the delay before the first retry of fetching.
+	defaultRetryBackoff = time.Second
+
+	// maxRetryDelay is the longest delay before retrying, including the
+	// delay requested by the server using Retry-After header.
+	maxRetryDelay = 30 * time.Second
+
+	// defaultMinParagraphLength is the minimum number of characters for
+	// a paragraph to be counted when scoring the content.
+	defaultMinParagraphLength = 25
+
+	// defaultMaxInlineImageSize and defaultMaxInlineImagesSize are the
+	// maximum size of each inlined image and of all of them, in bytes.
+	defaultMaxInlineImageSize  = 1 << 20
+	defaultMaxInlineImagesSize = 10 << 20
+
+	// defaultInlineImageConcurrency is the number of images that fetched
+	// at the same time when inlining images.
+	defaultInlineImageConcurrency = 4
+
+	// defaultInlineImageTimeout is the time limit for fetching each image
+	// when inlining images.
+	defaultInlineImageTimeout = 10 * time.Second
+)
+
+// Options is the configuration for parsing an article. The zero value of each
+// field means the default behavior will be used.
+type Options struct {
+	// HTTPClient is the client used to fetch the page. If it's nil,
+	// http.DefaultClient will be used.
+	HTTPClient *http.Client
+
+	// Timeout is the time limit for the whole fetching, including reading the
+	// response body. ConnectTimeout is the time limit for establishing the
+	// connection, and ResponseHeaderTimeout is the time limit for waiting the
+	// response header after the request is sent. This way a slow page can be
+	// given a generous time to be read while unresponsive server is still
+	// given up early. They're only used when HTTPClient is nil, since the
+	// client should be configured by its owner. If they're zero, there is
+	// no limit.
+	Timeout               time.Duration
+	ConnectTimeout        time.Duration
+	ResponseHeaderTimeout time.Duration
+
+	// MaxRedirects is the maximum number of redirects followed when fetching
+	// the page. If the page redirects more than that, ErrTooManyRedirects is
+	// returned. If it's zero, the redirect policy of HTTPClient is used.
+	MaxRedirects int
+
+	// MaxRetries is the maximum number of retries when fetching the page fails
+	// because of network error or temporary server error, i.e. status 429,
+	// 502, 503 and 504. RetryBackoff is the delay before the first retry,
+	// which is doubled for each next retry. If the server sends Retry-After
+	// header, its delay is used instead. The delay is never longer than 30
+	// seconds. If MaxRetries is zero, the page is not retried. If RetryBackoff
+	// is zero, it will be one second.
+	MaxRetries   int
+	RetryBackoff time.Duration
+
+	// MaxPages is the maximum number of pages fetched for article which split
+	// into several pages. The next pages are found using Metadata.NextPageURL,
+	// and their content is appended to the article. If it's zero or one, only
+	// the requested page is fetched.
+	MaxPages int
+
+	// UserAgent is the value of User-Agent header that sent when fetching
+	// the page. If it's empty, the default user agent of HTTP client is used.
+	UserAgent string
+
+	// Header is the additional headers that sent when fetching the page, e.g.
+	// Accept-Language to get the page in certain language. The User-Agent
+	// here is overridden by UserAgent if it's not empty.
+	Header http.Header
+
+	// MinParagraphLength is the minimum number of characters for a paragraph
+	// to be counted when scoring the content. If it's zero, it will be 25.
+	// The length is counted in characters (runes), not bytes, so a CJK
+	// paragraph is measured the same way as a latin one. Lower it to keep
+	// short paragraphs in dense content, or raise it for better precision.
+	MinParagraphLength int
+
+	// MinContentLength is the minimum number of characters in the text of
+	// content. If the content is shorter, e.g. a paywall stub, the extraction
+	// is retried with relaxed rules, and ErrContentTooShort is returned if
+	// it's still too short. If it's zero, there is no minimum length.
+	MinContentLength int
+
+	// DisableClassWeight disables using class name and id of the elements
+	// to decide whether they look like content or not.
+	DisableClassWeight bool
+
+	// MaxElements is the maximum number of elements in the page. If the page
+	// has more elements, parsing will be stopped and ErrTooManyElements will be
+	// returned. This protects from huge pages that are slow to process.
+	// If it's zero, there is no limit.
+	MaxElements int
+
+	// PreferSelector is the CSS selector of elements that known to contain the
+	// content, e.g. ".entry-content" in WordPress sites. If it matches elements
+	// with enough text, they're used as the content without scoring. If it
+	// doesn't match or the selector is invalid, the content is searched as usual.
+	PreferSelector string
+
+	// RemoveSelectors is the list of CSS selectors of elements that known to
+	// be junk, e.g. cookie banners or app install prompts. The matched elements
+	// are removed before the content is searched, so they can't be selected.
+	// Invalid selectors are ignored.
+	RemoveSelectors []string
+
+	// UnlikelyCandidates is the pattern of class name and id of elements that
+	// unlikely to be the content, so they will be removed before scoring.
+	// OkMaybeItsACandidate is the pattern of class name and id that exempts
+	// an element from that removal. If they're nil, the built-in patterns
+	// will be used.
+	UnlikelyCandidates   *regexp.Regexp
+	OkMaybeItsACandidate *regexp.Regexp
+
+	// LightClean keeps the content close to the original HTML. The unlikely
+	// elements like navigation and ads are still removed while searching the
+	// content, but the content itself is not cleaned, so its class names, ids
+	// and styling attributes are kept. The URLs are still made absolute. Use it
+	// to re-style the content using the page's own classes.
+	LightClean bool
+
+	// FlattenWrappers merges the <div> that only wraps another <div> before
+	// searching the content, so pages that nest their content dozens of
+	// elements deep are processed faster and give cleaner HTML. A wrapper is
+	// only merged if it or its child doesn't have any attribute.
+	FlattenWrappers bool
+
+	// CleanThresholds tunes the cleaning of content, which removes the blocks
+	// that don't look like part of the article. The zero value of each field
+	// means the default threshold is used.
+	CleanThresholds CleanThresholds
+
+	// AllowedEmbeds is the pattern of URL of embedded content like video
+	// player, which iframe, object and embed will be kept in the content.
+	// If it's nil, the built-in pattern will be used, which covers common
+	// providers like YouTube, Vimeo, Wistia, Twitch, SoundCloud and Spotify.
+	// Use it to add other providers or self-hosted players.
+	AllowedEmbeds *regexp.Regexp
+
+	// PreserveAttributes is the list of attributes that will be kept in the
+	// content. By default class, id and presentational attributes like style
+	// and width are removed, so use this to keep e.g. the class names of
+	// syntax highlighting or the ids that targeted by footnote links.
+	PreserveAttributes []string
+
+	// ReadSpeedCPM is the read speed in characters per minute. If it's zero,
+	// the read speed will be taken from the built-in table based on the article
+	// language. Since a custom read speed has no deviation, the estimated
+	// minimum and maximum read time will be the same.
+	ReadSpeedCPM float64
+
+	// SkipReadTime disables the read time estimation, which detects the
+	// language of the whole content. Enable it to save time when the read
+	// time is not used, in which case MinReadTime and MaxReadTime will be zero.
+	SkipReadTime bool
+
+	// ImageReadTime is the time needed to see each image in the article.
+	// If it's zero, it will be 12 seconds. Use a negative value to exclude
+	// images from the read time estimation.
+	ImageReadTime time.Duration
+
+	// MaxExcerptLength is the maximum number of characters in the excerpt.
+	// The longer excerpt will be cut on word boundary and appended with an
+	// ellipsis. If it's zero, the excerpt is not truncated.
+	MaxExcerptLength int
+
+	// KeepInvisibleChars keeps the invisible characters like zero width space,
+	// byte order mark and soft hyphen in the text and Markdown content. By
+	// default they're removed, since they break word counting and search
+	// indexing. They're always kept in the HTML content.
+	KeepInvisibleChars bool
+
+	// JoinLines rejoins the lines in text content that look hard wrapped, e.g.
+	// in pages converted from PDF, and merges the words that split by hyphen
+	// at the end of line. A line break is considered a hard wrap when the line
+	// doesn't end a sentence and the next line starts with lowercase letter.
+	// Preformatted text, tables and lists are kept as they are.
+	JoinLines bool
+
+	// IncludeHeroImage puts the page image, i.e. Metadata.Image, at the top of
+	// the content if the content doesn't have it yet. This way the content can
+	// be shown as it is, without the image missing or shown twice.
+	IncludeHeroImage bool
+
+	// OuterHTML makes RawContent include the <div> that wraps the content,
+	// instead of only its inner HTML. It's useful to mount the content as a
+	// single element. The element selected as content, e.g. <article> or
+	// <section>, is kept inside the wrapper either way.
+	OuterHTML bool
+
+	// ImageAltText writes the alt text of images into the text content, as
+	// "[alt text]", so the images are not lost from the text. Alt text that
+	// is only a generic word like "image" or a file name is skipped, since
+	// it's usually used for decorative images.
+	ImageAltText bool
+
+	// InlineImages fetches the images in content and embeds them into
+	// RawContent as data URI, so the HTML can be saved as a single file for
+	// offline reading. Text, Markdown and Images keep the image URLs. Image
+	// that fails to be fetched, isn't PNG, JPEG, GIF, WebP or AVIF, or
+	// exceeds the size limits keeps its URL as well. The images are fetched
+	// using HTTPClient, UserAgent and Header, even when the page itself is
+	// parsed from HTML, e.g. using ParseHTML.
+	InlineImages bool
+
+	// InlineImageTimeout is the time limit for fetching each image when
+	// inlining images. If it's zero, it will be 10 seconds.
+	InlineImageTimeout time.Duration
+
+	// InlinePrivateImages allows inlining images from loopback, private and
+	// link-local addresses, e.g. localhost or 192.168.1.1. They're blocked by
+	// default, since the page might be untrusted and point its images to
+	// the internal services of the server that parses it. The address can
+	// only be checked when HTTPClient uses *http.Transport, so with other
+	// transports the images are only inlined if this is enabled.
+	InlinePrivateImages bool
+
+	// MaxInlineImageSize is the maximum size of each inlined image in bytes,
+	// and MaxInlineImagesSize is the maximum size of all inlined images in a
+	// page. If they're zero, they will be 1 MB and 10 MB. When the images
+	// exceed the total size, the ones at the beginning of content are
+	// inlined first.
+	MaxInlineImageSize  int64
+	MaxInlineImagesSize int64
+
+	// InlineImageConcurrency is the number of images that fetched at the
+	// same time when inlining images. If it's zero, it will be 4.
+	InlineImageConcurrency int
+
+	// Sanitize enables sanitizing the HTML content using allowlist of elements
+	// and attributes. Elements that can run script or load external content are
+	// removed, along with event handlers and URL with unsafe scheme like
+	// javascript:. Enable it when RawContent will be rendered as it is.
+	Sanitize bool
+
+	// Debug enables Article.Debug, which describes the element selected as
+	// the content. It's useful to diagnose a bad extraction.
+	Debug bool
+}
+
+// CleanThresholds is the thresholds used to decide whether a block inside the
+// content, i.e. table, list or div, is removed because it doesn't look like
+// part of the article. The zero value of each field means the default value
+// is used. To disable a check, use a value that never met, e.g. a negative
+// MinContentLength or a MaxLinkDensity above 1.
+type CleanThresholds struct {
+	// MinCommas is the number of commas that makes a block kept without any
+	// other checks, since it's likely a prose. The default is 10.
+	MinCommas int
+
+	// ListItemAllowance is how many list items more than paragraphs a block
+	// may have before it's removed as a list of links or navigation. The
+	// default is 100. Raise it to keep list-heavy content.
+	ListItemAllowance int
+
+	// MinParagraphImageRatio is the minimum number of paragraphs and figures
+	// per image for a block with several images. A block below it is removed
+	// as gallery or ads. The default is 0.5. Lower it to keep image-heavy
+	// content.
+	MinParagraphImageRatio float64
+
+	// MinContentLength is the minimum number of characters in a block without
+	// media. The shorter block is removed. The default is 25.
+	MinContentLength int
+
+	// MaxLinkDensity is the maximum link density of a block with low class
+	// weight, and MaxWeightedLinkDensity is for a block which class name or
+	// id looks like content. The denser block is removed. The defaults are
+	// 0.2 and 0.5.
+	MaxLinkDensity         float64
+	MaxWeightedLinkDensity float64
+
+	// MinEmbedContentLength is the minimum number of characters in a block
+	// with a single embed that's not allowed. The default is 75.
+	MinEmbedContentLength int
+}
+
+// withDefaults returns the thresholds with the zero fields replaced by
+// their default values.
+func (t CleanThresholds) withDefaults() CleanThresholds {
+	if t.MinCommas == 0 {
+		t.MinCommas = 10
+	}
+
+	if t.ListItemAllowance == 0 {
+		t.ListItemAllowance = 100
+	}
+
+	if t.MinParagraphImageRatio == 0 {
+		t.MinParagraphImageRatio = 0.5
+	}
+
+	if t.MinContentLength == 0 {
+		t.MinContentLength = 25
+	}
+
+	if t.MaxLinkDensity == 0 {
+		t.MaxLinkDensity = 0.2
+	}
+
+	if t.MaxWeightedLinkDensity == 0 {
+		t.MaxWeightedLinkDensity = 0.5
+	}
+
+	if t.MinEmbedContentLength == 0 {
+		t.MinEmbedContentLength = 75
+	}
+
+	return t
+}
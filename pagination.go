@@ -0,0 +1,156 @@
+package readability
+
+import (
+	"context"
+	"github.com/PuerkitoBio/goquery"
+	nurl "net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// nextPageText matches the text of link to the next page, e.g. "Next",
+	// "Next page »" or a lone arrow.
+	nextPageText = regexp.MustCompile(`(?i)^(next( page)?\s*[›»→>]*|[›»→]+)$`)
+
+	// pageNumberPath matches the page number at the end of URL path, e.g.
+	// /story/2, /story/page/2, /story-2 or /story_p2.
+	pageNumberPath = regexp.MustCompile(`(?i)(/(page|p)?[/\-_]?\d{1,3}|[\-_](page|p)?\d{1,3})/?$`)
+
+	// pageQueryKeys is the query parameters that used for page number.
+	pageQueryKeys = []string{"page", "p", "pg", "paged", "pagenum", "start"}
+)
+
+// Find the URL of next page for article that split into several pages. It's
+// taken from <link rel="next">, or from link which rel is next or which text
+// looks like a link to next page. The links in header, footer, navigation,
+// sidebar and comments are skipped, since they usually link to the next post
+// or to the next page of comments. Only URL that shares the path of page and
+// differs by page number is used.
+func (r *readability) getNextPageURL(doc *goquery.Document) string {
+	nextURL := ""
+	doc.Find("link[rel], a[href]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		if s.Is("a") && s.ParentsFiltered("header,footer,nav,aside,[class*=comment],[id*=comment]").Length() > 0 {
+			return true
+		}
+
+		isNext := false
+		for _, rel := range strings.Fields(strings.ToLower(s.AttrOr("rel", ""))) {
+			if rel == "next" {
+				isNext = true
+				break
+			}
+		}
+
+		if !isNext && s.Is("a") {
+			isNext = nextPageText.MatchString(NormalizeText(s.Text()))
+		}
+
+		if isNext {
+			nextURL = r.nextPageURL(s.AttrOr("href", ""))
+		}
+
+		return nextURL == ""
+	})
+
+	return nextURL
+}
+
+// Returns the absolute URL if it's another page of the article, i.e. in the
+// same host and path as the page but with different page number. Otherwise
+// returns empty string.
+func (r *readability) nextPageURL(href string) string {
+	parsedURL, err := nurl.Parse(strings.TrimSpace(href))
+	if err != nil || strings.TrimSpace(href) == "" {
+		return ""
+	}
+
+	parsedURL = r.resolveReference(parsedURL)
+	parsedURL.Fragment = ""
+	if (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") ||
+		!strings.EqualFold(parsedURL.Host, r.url.Host) {
+		return ""
+	}
+
+	pageURL := *r.url
+	pageURL.Fragment = ""
+	if parsedURL.String() == pageURL.String() || !isNextPageOf(parsedURL, &pageURL) {
+		return ""
+	}
+
+	return parsedURL.String()
+}
+
+// isNextPageOf checks if the URL is another page of the page URL, i.e. it has
+// page number either in the end of path or in the query, and its path is the
+// same as the page once their page numbers are removed.
+func isNextPageOf(parsedURL, pageURL *nurl.URL) bool {
+	// The extension is removed first, so /story-2.html is matched as well
+	trimPath := func(urlPath string) string {
+		urlPath = strings.TrimSuffix(urlPath, path.Ext(urlPath))
+		return strings.TrimSuffix(pageNumberPath.ReplaceAllString(urlPath, ""), "/")
+	}
+
+	hasPageQuery := false
+	query := parsedURL.Query()
+	for _, key := range pageQueryKeys {
+		if _, err := strconv.Atoi(query.Get(key)); err == nil {
+			hasPageQuery = true
+			break
+		}
+	}
+
+	if hasPageQuery {
+		return strings.TrimSuffix(parsedURL.Path, "/") == strings.TrimSuffix(pageURL.Path, "/")
+	}
+
+	nextPath := strings.TrimSuffix(parsedURL.Path, path.Ext(parsedURL.Path))
+	return pageNumberPath.MatchString(nextPath) && trimPath(parsedURL.Path) == trimPath(pageURL.Path)
+}
+
+// appendNextPages fetches the next pages of article, up to MaxPages pages in
+// total, then appends their content to the article. It stops at the first
+// page that fails, so the article still has the content from previous pages.
+// Meta.NextPageURL is left pointing to the page that isn't fetched, if any.
+func appendNextPages(ctx context.Context, article Article, opts Options) Article {
+	// The next pages are fetched without pagination, since it's done here
+	pageOpts := opts
+	pageOpts.MaxPages = 0
+
+	visited := map[string]struct{}{article.URL: {}}
+	for nPages := 1; nPages < opts.MaxPages; nPages++ {
+		nextURL := article.Meta.NextPageURL
+		if _, exist := visited[nextURL]; exist || nextURL == "" {
+			break
+		}
+		visited[nextURL] = struct{}{}
+
+		page, err := parseURL(ctx, nextURL, pageOpts)
+		if err != nil {
+			break
+		}
+
+		article.Content = joinContent(article.Content, page.Content)
+		article.RawContent += page.RawContent
+		article.Markdown = joinContent(article.Markdown, page.Markdown)
+		article.Images = append(article.Images, page.Images...)
+		article.Headings = append(article.Headings, page.Headings...)
+		article.Blocks = append(article.Blocks, page.Blocks...)
+		article.Meta.MinReadTime += page.Meta.MinReadTime
+		article.Meta.MaxReadTime += page.Meta.MaxReadTime
+		article.Meta.NextPageURL = page.Meta.NextPageURL
+	}
+
+	return article
+}
+
+// joinContent joins the text of two pages as separate paragraphs.
+func joinContent(content, nextContent string) string {
+	if content == "" || nextContent == "" {
+		return content + nextContent
+	}
+
+	return content + "\n\n" + nextContent
+}
@@ -1,21 +1,37 @@
 package readability
 
 import (
+	"bufio"
 	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
 	wl "github.com/abadojack/whatlanggo"
+	"github.com/andybalholm/brotli"
 	"golang.org/x/net/html"
 	"golang.org/x/net/html/atom"
-	ghtml "html"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/net/publicsuffix"
+	"io"
 	"io/ioutil"
 	"math"
+	"net"
 	"net/http"
 	nurl "net/url"
-	pt "path"
+	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 var (
@@ -25,16 +41,65 @@ var (
 	negative             = regexp.MustCompile(`(?is)hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|foot|footer|footnote|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
 	extraneous           = regexp.MustCompile(`(?is)print|archive|comment|discuss|e[\-]?mail|share|reply|all|login|sign|single|utility`)
 	byline               = regexp.MustCompile(`(?is)byline|author|dateline|writtenby|p-author`)
-	divToPElements       = regexp.MustCompile(`(?is)<(a|blockquote|dl|div|img|ol|p|pre|table|ul|select)`)
-	replaceBrs           = regexp.MustCompile(`(?is)(<br[^>]*>[ \n\r\t]*){2,}`)
+	bylinePrefix         = regexp.MustCompile(`(?i)^by\b\s*:?\s*`)
+	divToPElements       = regexp.MustCompile(`(?is)<(a|blockquote|details|dl|div|img|ol|p|pre|table|ul|select)`)
 	killBreaks           = regexp.MustCompile(`(?is)(<br\s*/?>(\s|&nbsp;?)*)+`)
-	videos               = regexp.MustCompile(`(?is)//(www\.)?(dailymotion|youtube|youtube-nocookie|player\.vimeo)\.com`)
+	videos               = regexp.MustCompile(`(?is)//(www\.)?((dailymotion|youtube|youtube-nocookie|player\.vimeo|player\.twitch|clips\.twitch|w\.soundcloud|open\.spotify|platform\.twitter|embed\.ted|streamable|players\.brightcove|archive)\.(com|net|tv|org)|(fast\.)?wistia\.(com|net)|(player\.)?bilibili\.com|v\.qq\.com|upload\.wikimedia\.org|facebook\.com/plugins/video)`)
 	unlikelyElements     = regexp.MustCompile(`(?is)(input|time|button)`)
 	pIsSentence          = regexp.MustCompile(`(?is)\.( |$)`)
 	spaces               = regexp.MustCompile(`(?is)\s{2,}`)
 	comments             = regexp.MustCompile(`(?is)<!--[^>]+-->`)
+	quoteEntities        = strings.NewReplacer("&#34;", `"`, "&#39;", "'")
+	hyphenatedBreak      = regexp.MustCompile(`(\pL)-[ \t]*\n\s*(\p{Ll})`)
+	articlePath          = regexp.MustCompile(`(?i)/\d{4}/|[a-z0-9]+[-_][a-z0-9]+[-_][a-z0-9]+|\.s?html?$|/\d{5,}`)
+	paywallMarkers       = regexp.MustCompile(`(?i)(^|[-_\s])(paywall|regwall|metered)([-_\s]|$)`)
+	subscribeCTA         = regexp.MustCompile(`(?i)\b(subscribe|subscription|subscribers?|(sign|log) in to (read|continue))\b`)
+	placeholderImages    = regexp.MustCompile(`(?i)(^|/)(spacer|blank|pixel|transparent|placeholder|lazy[-_]?load)[^/]*\.(gif|png|svg)(\?|#|$)`)
+	trivialAltText       = regexp.MustCompile(`(?i)^([^\pL\pN]*|(an? )?(image|img|photo|picture|pic|icon|logo|spacer|thumbnail|banner|placeholder)( ?\d+)?|\S+\.(jpe?g|png|gif|webp|avif|svg|bmp))$`)
 )
 
+var (
+	// unlikelyRoles is the roles of elements that unlikely to be the content.
+	unlikelyRoles = map[string]struct{}{
+		"menu":          {},
+		"menubar":       {},
+		"complementary": {},
+		"navigation":    {},
+		"alert":         {},
+		"alertdialog":   {},
+		"dialog":        {},
+	}
+
+	// widgetAttributes is the data attributes that commonly used to identify
+	// widgets like share bar or newsletter popup.
+	widgetAttributes = []string{"data-widget", "data-testid", "data-component", "data-module"}
+	unlikelyWidgets  = regexp.MustCompile(`(?i)share|sharing|social|newsletter|subscribe|signup|popup|modal|promo|related|recommend|comment|sponsor|advert|tracking`)
+
+	// commentWidgets and commentFrames are the patterns of class name, id
+	// and iframe URL of comment sections from common comment platforms.
+	commentWidgets = regexp.MustCompile(`(?i)fb-comments|fb_comments|discourse-comments|coral-|coral_|talk-embed|livefyre|commento|utterances|giscus|hyvor-talk|remark42|isso-thread|vuukle|spot-im|openweb|intensedebate|gitalk|graphcomment|hypercomments`)
+	commentFrames  = regexp.MustCompile(`(?i)disqus\.com|facebook\.com/plugins/(comments|feedback)|/embed/comments|coral\.|livefyre\.com|utteranc\.es|giscus\.app|hyvor\.com|vuukle\.com|spot\.im|intensedebate\.com|graphcomment\.com|hypercomments\.com`)
+)
+
+// minArticleScore and minArticleLength are the minimum score and the minimum
+// number of characters of a candidate to be extracted as an article when
+// parsing multiple articles from a page.
+const (
+	minArticleScore  = 10.0
+	minArticleLength = 140
+)
+
+// minHeroImageSize is the minimum width and height of an image in content to
+// be used as the article image, so icons and tracking pixels are skipped.
+const minHeroImageSize = 100
+
+// imageSize is the width and height declared by an image. It's zero if
+// the dimension is not declared.
+type imageSize struct {
+	width  int
+	height int
+}
+
 type candidateItem struct {
 	score float64
 	node  *goquery.Selection
@@ -43,115 +108,1166 @@ type candidateItem struct {
 type readability struct {
 	html       string
 	url        *nurl.URL
-	candidates map[string]candidateItem
+	baseURL    *nurl.URL
+	opts       Options
+	candidates map[*html.Node]*candidateItem
+	dataTables map[*html.Node]struct{}
+	imageSizes map[*html.Node]imageSize
+	debug      *DebugInfo
+	byline     string
+	title      string
 }
 
 // Metadata is metadata of an article
 type Metadata struct {
-	Title       string
-	Image       string
-	Excerpt     string
-	Author      string
-	MinReadTime int
-	MaxReadTime int
+	Title        string
+	Image        string
+	Excerpt      string
+	Author       string
+	SiteName     string
+	CanonicalURL string
+	Favicon      string
+	Language     string
+	Tags         []string
+	MinReadTime  int
+	MaxReadTime  int
+
+	// NextPageURL is the URL of the next page, for article which split into
+	// several pages. When the pages are fetched using Options.MaxPages, it's
+	// the URL of the page after the last fetched one.
+	NextPageURL string
+
+	// Truncated is a best-effort signal that the content is only part of the
+	// article, e.g. the preview before a paywall. It's set when the page has
+	// paywall elements, declares that it isn't free in JSON-LD, or when the
+	// content ends with a call to subscribe.
+	Truncated bool
+
+	// Authors is the list of the article authors, since an article may be
+	// written by several people. Author contains the same names joined with
+	// comma, so it's still usable for display.
+	Authors []string
+
+	// MetaDescription is the raw value of <meta name="description">. Excerpt
+	// uses it when it's available, but sometimes it's an SEO text which worse
+	// than the first paragraph, so it's provided separately.
+	MetaDescription string
+
+	// PublishedTime is the time when the article published. If the page
+	// declares it in format that can't be parsed, PublishedTime will be zero
+	// and the declared value is still available in RawPublishedTime.
+	PublishedTime    time.Time
+	RawPublishedTime string
+}
+
+// Image is an image inside the article content
+type Image struct {
+	URL string
+	Alt string
+}
+
+// Heading is a heading inside the article content, which can be used to build
+// table of contents. ID is the id of heading element, which is kept in the HTML
+// content so it can be linked to. It's empty if the page doesn't declare it.
+type Heading struct {
+	Level int
+	Text  string
+	ID    string
+}
+
+// DebugInfo describes how the content of an article is selected. It's only
+// filled when Options.Debug is enabled.
+type DebugInfo struct {
+	// TagName is the tag name of the top candidate, i.e. the element with
+	// the highest score which becomes the base of the content.
+	TagName string
+
+	// Score and LinkDensity are the final score and the link density of
+	// the top candidate.
+	Score       float64
+	LinkDensity float64
+
+	// Candidates is the number of elements that considered as candidate.
+	// It's zero when the content is taken from Options.PreferSelector.
+	Candidates int
+}
+
+// Candidate is an element that considered as the content, along with its
+// score. It's used to understand why a page is extracted the way it is.
+type Candidate struct {
+	TagName   string
+	ClassName string
+	ID        string
+
+	// Score and LinkDensity are the final score and the link density of
+	// the element. The element with the highest score becomes the content.
+	Score       float64
+	LinkDensity float64
 }
 
 // Article is the content of an URL
 type Article struct {
-	URL        string
-	Meta       Metadata
-	Content    string
+	URL     string
+	Meta    Metadata
+	Content string
+
+	// RawContent is the HTML of content. It already includes the element
+	// selected as content, e.g. <article> or <section lang="en">. Enable
+	// Options.OuterHTML to include the <div> that wraps them as well.
 	RawContent string
+	Markdown   string
+	Images     []Image
+	Headings   []Heading
+
+	// Blocks is the content as a list of paragraphs, headings, images,
+	// lists, quotes and codes, for rendering it without HTML.
+	Blocks []Block
+
+	// StatusCode and ContentType are taken from the HTTP response of the
+	// page. They're only filled when the page is fetched by this package.
+	StatusCode  int
+	ContentType string
+
+	// Debug explains why the content is selected. It's nil unless
+	// Options.Debug is enabled.
+	Debug *DebugInfo
+}
+
+// Readability extracts the readable article from web pages. It holds the
+// options, so it can be configured once and reused to parse many pages.
+// It's safe for concurrent use as long as its options are not modified.
+type Readability struct {
+	opts Options
+}
+
+// New returns a Readability that parses pages using the specified options.
+// The HTTP client is created once here, so the connections are reused by
+// every page fetched using the same Readability.
+func New(opts Options) *Readability {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = newHTTPClient(opts)
+	}
+
+	return &Readability{opts: opts}
+}
+
+// Parse fetches the page in the specified URL and parses it to readability format.
+// Only HTTP and HTTPS URLs are fetched. File URL is refused, since the URL often
+// comes from user and reading it would expose the local files, so use
+// ParseFile to read local file instead.
+func (rd *Readability) Parse(url string) (Article, error) {
+	return parseURL(context.Background(), url, rd.opts)
+}
+
+// ParseContext fetches the page in the specified URL and parses it to
+// readability format. The context is used for fetching the page as well as
+// for extracting the article.
+func (rd *Readability) ParseContext(ctx context.Context, url string) (Article, error) {
+	return parseURL(ctx, url, rd.opts)
+}
+
+// ParseRequest fetches the page using the specified request and parses it
+// to readability format. The request is sent as it is, so Options.UserAgent
+// and Options.Header are not applied to it.
+func (rd *Readability) ParseRequest(req *http.Request) (Article, error) {
+	return parseRequest(req, rd.opts)
+}
+
+// ParseHTML parses a raw HTML page to readability format. The pageURL is
+// used to resolve relative links inside the content. Nothing is fetched from
+// network, unless Options.InlineImages is enabled.
+func (rd *Readability) ParseHTML(rawHTML string, pageURL string) (Article, error) {
+	// Make sure url is valid
+	parsedURL, err := nurl.Parse(pageURL)
+	if err != nil {
+		return Article{}, err
+	}
+
+	return parse(context.Background(), strings.NewReader(rawHTML), parsedURL, rd.opts)
+}
+
+// ParseReader parses HTML page from the reader to readability format. If the
+// page is not encoded in UTF-8, it will be converted using the charset declared
+// in the page. The pageURL is used to resolve relative links inside the content.
+func (rd *Readability) ParseReader(r io.Reader, pageURL string) (Article, error) {
+	// Make sure url is valid
+	parsedURL, err := nurl.Parse(pageURL)
+	if err != nil {
+		return Article{}, err
+	}
+
+	// Convert the page to UTF-8
+	reader, err := charset.NewReader(r, "")
+	if err != nil {
+		return Article{}, err
+	}
+
+	return parse(context.Background(), reader, parsedURL, rd.opts)
+}
+
+// ParseFile parses HTML page saved in the local file to readability format.
+// The path is either a file path or a file:// URL. The baseURL is the address
+// the page was retrieved from, and is used to resolve relative links inside
+// the content. If it's empty, the file URL of the path is used instead.
+func (rd *Readability) ParseFile(path string, baseURL string) (Article, error) {
+	path, err := filePath(path)
+	if err != nil {
+		return Article{}, err
+	}
+
+	parsedURL, err := fileURL(path, baseURL)
+	if err != nil {
+		return Article{}, err
+	}
+
+	return parseFile(context.Background(), path, parsedURL, rd.opts)
+}
+
+// ParseDocument parses an already built goquery document to readability
+// format. The document is modified while extracting the article. The pageURL
+// is used to resolve relative links inside the content.
+func (rd *Readability) ParseDocument(doc *goquery.Document, pageURL string) (Article, error) {
+	// Make sure url is valid
+	parsedURL, err := nurl.Parse(pageURL)
+	if err != nil {
+		return Article{}, err
+	}
+
+	return parseDocument(context.Background(), doc, parsedURL, rd.opts)
+}
+
+// ParseAll parses a raw HTML page which contains several articles, e.g. index
+// page or newsletter archive. At most n articles are returned, ordered by their
+// score. If n is zero or negative, all articles found are returned.
+func (rd *Readability) ParseAll(rawHTML string, pageURL string, n int) ([]Article, error) {
+	// Make sure url is valid
+	parsedURL, err := nurl.Parse(pageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	doc, err := newDocument(ctx, strings.NewReader(rawHTML))
+	if err != nil {
+		return nil, err
+	}
+
+	return parseAllDocument(ctx, doc, parsedURL, rd.opts, n)
+}
+
+// ParseFragment parses an HTML fragment, e.g. the full content of RSS entry,
+// to readability format. The baseURL is used to resolve relative links inside
+// the fragment. Nothing is fetched from network, unless Options.InlineImages
+// is enabled.
+func (rd *Readability) ParseFragment(fragment string, baseURL string) (Article, error) {
+	// Make sure url is valid
+	parsedURL, err := nurl.Parse(baseURL)
+	if err != nil {
+		return Article{}, err
+	}
+
+	return parseFragment(context.Background(), fragment, parsedURL, rd.opts)
+}
+
+// ScoreCandidates scores a raw HTML page like when parsing it, then returns
+// the elements that considered as the content, ordered by their score. At most
+// n candidates are returned, or all of them if n is zero or negative.
+func (rd *Readability) ScoreCandidates(rawHTML string, pageURL string, n int) ([]Candidate, error) {
+	// Make sure url is valid
+	parsedURL, err := nurl.Parse(pageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := newDocument(context.Background(), strings.NewReader(rawHTML))
+	if err != nil {
+		return nil, err
+	}
+
+	return scoreDocument(doc, parsedURL, rd.opts, n)
+}
+
+// Parse an URL to readability format. The timeout covers the whole fetching,
+// from connecting to reading the response body. To limit connection and response
+// header separately, use ParseWithOptions with ConnectTimeout and
+// ResponseHeaderTimeout options.
+func Parse(url string, timeout time.Duration) (Article, error) {
+	return ParseWithClient(url, &http.Client{Timeout: timeout})
+}
+
+// ParseWithClient parses an URL to readability format, using the specified
+// HTTP client to fetch the page. This is useful when the page must be fetched
+// through a proxy or a custom transport.
+func ParseWithClient(url string, client *http.Client) (Article, error) {
+	return New(Options{HTTPClient: client}).Parse(url)
+}
+
+// ParseContext parses an URL to readability format. The context is used for
+// fetching the page as well as for extracting the article, so the whole process
+// will be aborted as soon as the context is cancelled or its deadline exceeded.
+func ParseContext(ctx context.Context, url string) (Article, error) {
+	return New(Options{}).ParseContext(ctx, url)
+}
+
+// ParseWithOptions parses an URL to readability format, using the specified options.
+func ParseWithOptions(url string, opts Options) (Article, error) {
+	return New(opts).Parse(url)
+}
+
+// ParseWithRequest fetches the page using the specified request and parses it
+// to readability format. Use it when the request needs full control, e.g. to
+// send cookies or to use method other than GET. The request is sent as it is,
+// so Options.UserAgent and Options.Header are not applied to it. The context
+// of the request is used for the whole process.
+func ParseWithRequest(req *http.Request, opts Options) (Article, error) {
+	return New(opts).ParseRequest(req)
+}
+
+// transportKey is the timeouts that need their own transport.
+type transportKey struct {
+	connectTimeout        time.Duration
+	responseHeaderTimeout time.Duration
+}
+
+// transports caches the transport for each transportKey, so the connections
+// are reused by every client with the same timeouts instead of leaking.
+var transports sync.Map
+
+// newHTTPClient creates the client for fetching page when Options.HTTPClient
+// is not specified. If there are no timeouts in options, http.DefaultClient
+// is used as it is. The transport is shared with the other clients that
+// have the same connect and response header timeouts.
+func newHTTPClient(opts Options) *http.Client {
+	if opts.Timeout <= 0 && opts.ConnectTimeout <= 0 && opts.ResponseHeaderTimeout <= 0 {
+		return http.DefaultClient
+	}
+
+	if opts.ConnectTimeout <= 0 && opts.ResponseHeaderTimeout <= 0 {
+		return &http.Client{Timeout: opts.Timeout}
+	}
+
+	key := transportKey{opts.ConnectTimeout, opts.ResponseHeaderTimeout}
+	if transport, ok := transports.Load(key); ok {
+		return &http.Client{Transport: transport.(*http.Transport), Timeout: opts.Timeout}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.ResponseHeaderTimeout > 0 {
+		transport.ResponseHeaderTimeout = opts.ResponseHeaderTimeout
+	}
+
+	if opts.ConnectTimeout > 0 {
+		dialer := &net.Dialer{Timeout: opts.ConnectTimeout, KeepAlive: 30 * time.Second}
+		transport.DialContext = dialer.DialContext
+		transport.TLSHandshakeTimeout = opts.ConnectTimeout
+	}
+
+	cached, _ := transports.LoadOrStore(key, transport)
+	return &http.Client{Transport: cached.(*http.Transport), Timeout: opts.Timeout}
+}
+
+// ParseHTML parses a raw HTML page to readability format, without fetching
+// anything from network. Use Readability.ParseHTML with Options.InlineImages
+// to fetch the images in content as well. The pageURL is the address the page was retrieved
+// from, and is used to resolve relative links inside the content.
+func ParseHTML(rawHTML string, pageURL string) (Article, error) {
+	return New(Options{}).ParseHTML(rawHTML, pageURL)
+}
+
+// ParseAll parses a raw HTML page which contains several distinct articles,
+// e.g. link aggregator or newsletter archive, where the assumption of single
+// main content is wrong. The articles are taken from the non-overlapping parts
+// of the page with the highest score. At most n articles are returned, or all
+// of them if n is zero or negative. Each article has its own title, taken
+// from its first heading, while the rest of metadata is shared.
+func ParseAll(rawHTML string, pageURL string, n int) ([]Article, error) {
+	return New(Options{}).ParseAll(rawHTML, pageURL, n)
+}
+
+// ParseFragment parses an HTML fragment to readability format, e.g. the full
+// content inside RSS or Atom entry. Unlike ParseHTML, the fragment is assumed
+// to be the content as a whole, so it's only cleaned without searching for the
+// content, and the metadata like title is not extracted from it. The baseURL
+// is used to resolve relative links inside the fragment.
+func ParseFragment(fragment string, baseURL string) (Article, error) {
+	return New(Options{}).ParseFragment(fragment, baseURL)
+}
+
+// ScoreCandidates returns the elements in a raw HTML page that considered as
+// the content, ordered by their score, which is useful for tuning and debugging
+// the extraction. At most n candidates are returned, or all of them if n is
+// zero or negative.
+func ScoreCandidates(rawHTML string, pageURL string, n int) ([]Candidate, error) {
+	return New(Options{}).ScoreCandidates(rawHTML, pageURL, n)
+}
+
+// ParseReader parses HTML page from the reader to readability format. The reader
+// is read until EOF, so it will be fully consumed once this function returns.
+// It's not closed though, so closing it is still the caller's responsibility.
+// If the page is not encoded in UTF-8, it will be converted using the charset
+// declared in the page. The pageURL is used to resolve relative links inside
+// the content.
+func ParseReader(r io.Reader, pageURL string) (Article, error) {
+	return New(Options{}).ParseReader(r, pageURL)
+}
+
+// ParseFile parses HTML page saved in the local file to readability format,
+// which is useful for archived pages. The path is either a file path or a
+// file:// URL. The baseURL is the original address of the page, used to
+// resolve relative links inside the content. If it's empty, the file URL of
+// the path is used instead. Parse doesn't accept file:// URL, since the URL
+// often comes from user, so local files can only be read using ParseFile.
+func ParseFile(path string, baseURL string) (Article, error) {
+	return New(Options{}).ParseFile(path, baseURL)
+}
+
+// filePath returns the local path of file:// URL, or the path as it is if
+// it's not a file URL. File URL in other host is not supported.
+func filePath(path string) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(path), "file:") {
+		return path, nil
+	}
+
+	parsedURL, err := nurl.Parse(path)
+	if err != nil {
+		return "", err
+	}
+
+	if parsedURL.Host != "" && parsedURL.Host != "localhost" {
+		return "", fmt.Errorf("file URL in host %q is not supported", parsedURL.Host)
+	}
+
+	return filepath.FromSlash(parsedURL.Path), nil
+}
+
+// fileURL returns the parsed base URL, or the file URL of the path if the
+// base URL is empty.
+func fileURL(path string, baseURL string) (*nurl.URL, error) {
+	if baseURL != "" {
+		return nurl.Parse(baseURL)
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &nurl.URL{Scheme: "file", Path: filepath.ToSlash(absPath)}, nil
+}
+
+// parseFile reads the page from local file and extracts its article.
+func parseFile(ctx context.Context, path string, parsedURL *nurl.URL, opts Options) (Article, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Article{}, err
+	}
+	defer file.Close()
+
+	// Convert the page to UTF-8, using charset declared in the page
+	reader, err := charset.NewReader(file, "")
+	if err != nil {
+		return Article{}, err
+	}
+
+	return parse(ctx, reader, parsedURL, opts)
+}
+
+// parseURL fetches the page in the specified URL and extracts its article.
+func parseURL(ctx context.Context, url string, opts Options) (Article, error) {
+	// Prepare request for the URL
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Article{}, err
+	}
+
+	for key, values := range opts.Header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	if opts.UserAgent != "" {
+		req.Header.Set("User-Agent", opts.UserAgent)
+	}
+
+	return parseRequest(req, opts)
+}
+
+// parseRequest sends the request and extracts the article from its response.
+func parseRequest(req *http.Request, opts Options) (Article, error) {
+	ctx := req.Context()
+
+	// Fetch page from URL
+	client := opts.HTTPClient
+	if client == nil {
+		client = newHTTPClient(opts)
+	}
+
+	// Limit the redirects using a copy of client, so the original one is
+	// untouched. Its own redirect policy is still applied after the limit.
+	if opts.MaxRedirects > 0 {
+		limitedClient := *client
+		checkRedirect := client.CheckRedirect
+		limitedClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if len(via) > opts.MaxRedirects {
+				return ErrTooManyRedirects
+			}
+
+			if checkRedirect != nil {
+				return checkRedirect(req, via)
+			}
+			return nil
+		}
+		client = &limitedClient
+	}
+
+	resp, err := sendRequest(client, req, opts)
+	if err != nil {
+		return Article{}, err
+	}
+	defer resp.Body.Close()
+
+	// Use the final URL after redirects, so relative links are resolved correctly
+	parsedURL := resp.Request.URL
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return Article{}, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	// Decompress the page, since the body is only decompressed by client
+	// when it requests the compression by itself
+	decodedBody, err := decodeBody(resp)
+	if err != nil {
+		return Article{}, err
+	}
+	defer decodedBody.Close()
+
+	// Convert the page to UTF-8, using charset from the header or the page itself
+	body, err := charset.NewReader(decodedBody, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return Article{}, err
+	}
+
+	article, err := parse(ctx, body, parsedURL, opts)
+	article.StatusCode = resp.StatusCode
+	article.ContentType = resp.Header.Get("Content-Type")
+
+	// Append the next pages of article which split into several pages
+	if err == nil && opts.MaxPages > 1 {
+		article = appendNextPages(ctx, article, opts)
+	}
+
+	return article, err
+}
+
+// sendRequest sends the request, and retries it with exponential backoff when
+// it fails because of network error or retryable status, up to MaxRetries.
+func sendRequest(client *http.Client, req *http.Request, opts Options) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		resp, err := client.Do(req)
+		if attempt >= opts.MaxRetries || !isRetryable(req, resp, err) {
+			return resp, err
+		}
+
+		delay := retryDelay(resp, attempt, opts.RetryBackoff)
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		// The request body has been consumed, so get a fresh one
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// isRetryable checks if the failed request is worth retrying, i.e. it fails
+// because of network error or the server is temporarily unavailable.
+func isRetryable(req *http.Request, resp *http.Response, err error) bool {
+	// The request can't be sent again if its body can't be recreated
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	if err != nil {
+		return req.Context().Err() == nil && !errors.Is(err, ErrTooManyRedirects)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryDelay returns how long to wait before the next attempt. The delay
+// from Retry-After header is used if the server sends it, otherwise the
+// backoff is doubled for each attempt. Either way, the delay is capped at
+// maxRetryDelay, so the server can't hold the parsing for too long.
+func retryDelay(resp *http.Response, attempt int, backoff time.Duration) time.Duration {
+	if resp != nil {
+		retryAfter := strings.TrimSpace(resp.Header.Get("Retry-After"))
+		if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+			if seconds > int(maxRetryDelay/time.Second) {
+				return maxRetryDelay
+			}
+			return time.Duration(seconds) * time.Second
+		}
+
+		if date, err := http.ParseTime(retryAfter); err == nil {
+			delay := time.Until(date)
+			switch {
+			case delay <= 0:
+				return 0
+			case delay > maxRetryDelay:
+				return maxRetryDelay
+			}
+			return delay
+		}
+	}
+
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+
+	// Double the backoff one by one instead of shifting, so it won't
+	// overflow when there are many retries
+	delay := backoff
+	for i := 0; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+
+	return delay
+}
+
+// decodeBody returns the response body decoded according to its content
+// encoding. Gzip, deflate and Brotli are supported, while the other encodings
+// like compress will return ErrUnsupportedEncoding.
+func decodeBody(resp *http.Response) (io.ReadCloser, error) {
+	body := resp.Body
+	encodings := strings.Split(resp.Header.Get("Content-Encoding"), ",")
+
+	// The encodings are listed in the order they're applied, so decode
+	// them in reverse
+	for i := len(encodings) - 1; i >= 0; i-- {
+		switch strings.ToLower(strings.TrimSpace(encodings[i])) {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			reader, err := gzip.NewReader(body)
+			if err != nil {
+				return nil, err
+			}
+			body = reader
+		case "deflate":
+			// Some servers send raw deflate stream instead of zlib format,
+			// so check the zlib header first
+			buffered := bufio.NewReader(body)
+			header, _ := buffered.Peek(2)
+			if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+				reader, err := zlib.NewReader(buffered)
+				if err != nil {
+					return nil, err
+				}
+				body = reader
+			} else {
+				body = flate.NewReader(buffered)
+			}
+		case "br":
+			body = ioutil.NopCloser(brotli.NewReader(body))
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedEncoding, encodings[i])
+		}
+	}
+
+	return body, nil
+}
+
+// parse extracts the article from HTML which located in the specified URL.
+// The context is checked between each step, so a cancelled context will stop
+// the extraction before the next step is started.
+func parse(ctx context.Context, reader io.Reader, parsedURL *nurl.URL, opts Options) (Article, error) {
+	doc, err := newDocument(ctx, reader)
+	if err != nil {
+		return Article{}, err
+	}
+
+	return parseDocument(ctx, doc, parsedURL, opts)
+}
+
+// newDocument reads the HTML page from reader and creates goquery document from it.
+func newDocument(ctx context.Context, reader io.Reader) (*goquery.Document, error) {
+	// The whole page is needed to check whether it's empty
+	btHTML, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	strHTML := string(btHTML)
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	strHTML = strings.TrimSpace(strHTML)
+
+	// Check if HTML page is empty
+	if strHTML == "" {
+		return nil, ErrEmptyHTML
+	}
+
+	// Create goquery document
+	return goquery.NewDocumentFromReader(strings.NewReader(strHTML))
+}
+
+// parseFragment cleans the HTML fragment and uses all of it as the content.
+func parseFragment(ctx context.Context, fragment string, parsedURL *nurl.URL, opts Options) (Article, error) {
+	doc, err := newDocument(ctx, strings.NewReader(fragment))
+	if err != nil {
+		return Article{}, err
+	}
+
+	// Create new readability
+	r := readability{
+		url:        parsedURL,
+		opts:       opts,
+		candidates: make(map[*html.Node]*candidateItem),
+	}
+
+	// Make sure the fragment is not too big to process
+	if opts.MaxElements > 0 && doc.Find("*").Length() > opts.MaxElements {
+		return Article{}, ErrTooManyElements
+	}
+
+	// The fragment is parsed as the body of a page, so move the body content
+	// into a new node like the content found in a page
+	r.prepareDocument(doc)
+	contentNode := goquery.NewDocumentFromNode(&html.Node{
+		Type:     html.ElementNode,
+		Data:     "div",
+		DataAtom: atom.Div,
+	}).Selection
+	contentNode.AppendSelection(doc.Find("body").Contents())
+
+	r.prepArticle(contentNode)
+	if err := ctx.Err(); err != nil {
+		return Article{}, err
+	}
+
+	article := r.newArticle(ctx, Metadata{}, contentNode)
+	if NormalizeText(contentNode.Text()) == "" && contentNode.Find("img").Length() == 0 {
+		return article, ErrNoContent
+	}
+
+	if r.isContentTooShort(contentNode) {
+		return article, ErrContentTooShort
+	}
+
+	return article, nil
+}
+
+// ParseDocument parses an already built goquery document to readability
+// format. It's useful when the page is already parsed for other purposes, so
+// it doesn't need to be parsed again. Note that the document is modified while
+// extracting the article, so clone it first if it's still needed afterward.
+// The pageURL is used to resolve relative links inside the content.
+func ParseDocument(doc *goquery.Document, pageURL string) (Article, error) {
+	return New(Options{}).ParseDocument(doc, pageURL)
+}
+
+// parseDocument extracts the article from the document.
+func parseDocument(ctx context.Context, doc *goquery.Document, parsedURL *nurl.URL, opts Options) (Article, error) {
+	// Create new readability
+	r := readability{
+		url:        parsedURL,
+		opts:       opts,
+		candidates: make(map[*html.Node]*candidateItem),
+	}
+
+	// Make sure the page is not too big to process
+	if opts.MaxElements > 0 && doc.Find("*").Length() > opts.MaxElements {
+		return Article{}, ErrTooManyElements
+	}
+
+	// Get article metadata. It's done before the document prepared, since
+	// JSON-LD scripts and <time> elements will be removed in later steps.
+	meta := r.getArticleMetadata(doc)
+	r.title = meta.Title
+
+	// Prepare document and fetch content
+	r.prepareDocument(doc)
+	if err := ctx.Err(); err != nil {
+		return Article{}, err
+	}
+
+	contentNode := r.getArticleContent(doc)
+	if err := ctx.Err(); err != nil {
+		return Article{}, err
+	}
+
+	article := r.newArticle(ctx, meta, contentNode)
+	if contentNode == nil {
+		return article, ErrNoContent
+	}
+
+	if r.isContentTooShort(contentNode) {
+		return article, ErrContentTooShort
+	}
+
+	return article, nil
+}
+
+// newArticle creates article from the page metadata and its content node.
+func (r *readability) newArticle(ctx context.Context, meta Metadata, contentNode *goquery.Selection) Article {
+	// If the page doesn't declare its author, use the byline inside content
+	if meta.Author == "" && r.byline != "" {
+		meta.Author = r.byline
+		meta.Authors = []string{r.byline}
+	}
+
+	// Put the page image on top of content if it's not there yet, so the
+	// reader doesn't need to show it separately
+	if contentNode != nil && r.opts.IncludeHeroImage {
+		r.includeHeroImage(contentNode, meta.Image)
+	}
+
+	// Detect the content language once, since it's used by both the read
+	// time and the language metadata
+	var lang wl.Lang
+	if contentNode != nil && (!r.opts.SkipReadTime || meta.Language == "") {
+		lang = r.detectLanguage(contentNode)
+	}
+
+	// Estimate read time, unless it's not needed
+	if !r.opts.SkipReadTime {
+		meta.MinReadTime, meta.MaxReadTime = r.estimateReadTime(contentNode, lang)
+	}
+
+	// Get text and HTML from content
+	textContent := ""
+	htmlContent := ""
+	markdownContent := ""
+	var images []Image
+	var headings []Heading
+	var blocks []Block
+	if contentNode != nil {
+		// If the page doesn't declare its image, use the hero image in content
+		if meta.Image == "" {
+			meta.Image = r.getHeroImage(contentNode)
+		}
+
+		// If we haven't found an excerpt in the article's metadata, use the first paragraph
+		if meta.Excerpt == "" {
+			p := contentNode.Find("p").First().Text()
+			meta.Excerpt = NormalizeText(p)
+		}
+
+		meta.Excerpt = truncateText(meta.Excerpt, r.opts.MaxExcerptLength)
+
+		// Content that ends with subscribe prompt is likely cut by paywall
+		if !meta.Truncated {
+			meta.Truncated = r.hasSubscribePrompt(contentNode)
+		}
+
+		// If the page doesn't declare its language, detect it from the content
+		if meta.Language == "" {
+			meta.Language = languageCode(lang)
+		}
+
+		// Get content text and HTML
+		textContent = r.getTextContent(contentNode)
+
+		// Images are inlined in a copy of content, so the other formats
+		// keep the image URLs
+		htmlNode := contentNode
+		if r.opts.InlineImages {
+			htmlNode = contentNode.Clone()
+			r.inlineImages(ctx, htmlNode)
+		}
+		htmlContent = r.getHTMLContent(htmlNode)
+		markdownContent = r.getMarkdownContent(contentNode)
+
+		// Remove invisible characters from the text, since they break the
+		// word matching in search index. HTML keeps them, since browser
+		// uses soft hyphen to break long words.
+		if !r.opts.KeepInvisibleChars {
+			meta.Excerpt = removeInvisibleChars(meta.Excerpt)
+			textContent = removeInvisibleChars(textContent)
+			markdownContent = removeInvisibleChars(markdownContent)
+		}
+		images = r.getImages(contentNode)
+		headings = r.getHeadings(contentNode)
+		blocks = r.getBlocks(contentNode)
+	}
+
+	return Article{
+		URL:        r.url.String(),
+		Meta:       meta,
+		Content:    textContent,
+		RawContent: htmlContent,
+		Markdown:   markdownContent,
+		Images:     images,
+		Headings:   headings,
+		Blocks:     blocks,
+		Debug:      r.debug,
+	}
 }
 
-// Parse an URL to readability format
-func Parse(url string, timeout time.Duration) (Article, error) {
-	// Make sure url is valid
-	parsedURL, err := nurl.Parse(url)
-	if err != nil {
-		return Article{}, err
+// parseAllDocument extracts at most n articles from the document. Each article
+// is taken from a candidate which score and length are above the minimum, and
+// which doesn't overlap with the other articles.
+func parseAllDocument(ctx context.Context, doc *goquery.Document, parsedURL *nurl.URL, opts Options, n int) ([]Article, error) {
+	// Create new readability
+	r := readability{
+		url:        parsedURL,
+		opts:       opts,
+		candidates: make(map[*html.Node]*candidateItem),
 	}
 
-	// Fetch page from URL
-	client := &http.Client{Timeout: timeout}
-	resp, err := client.Get(url)
-	if err != nil {
-		return Article{}, err
+	// Make sure the page is not too big to process
+	if opts.MaxElements > 0 && doc.Find("*").Length() > opts.MaxElements {
+		return nil, ErrTooManyElements
 	}
-	defer resp.Body.Close()
 
-	btHTML, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return Article{}, err
+	// Get page metadata, then prepare document and score its content
+	pageMeta := r.getArticleMetadata(doc)
+	r.title = pageMeta.Title
+	r.prepareDocument(doc)
+	r.scoreCandidates(doc, true)
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-	strHTML := string(btHTML)
 
-	// Replaces 2 or more successive <br> elements with a single <p>.
-	// Whitespace between <br> elements are ignored. For example:
-	//   <div>foo<br>bar<br> <br><br>abc</div>
-	// will become:
-	//   <div>foo<br>bar<p>abc</p></div>
-	strHTML = replaceBrs.ReplaceAllString(strHTML, "</p><p>")
-	strHTML = strings.TrimSpace(strHTML)
+	var articles []Article
+	for _, candidate := range r.selectArticleCandidates(doc, n) {
+		// Each article has its own title and excerpt. The page title is only
+		// used if the article doesn't have any heading.
+		meta := pageMeta
+		meta.Excerpt = ""
+		if heading := candidate.node.Find("h1,h2,h3").First(); heading.Length() > 0 {
+			meta.Title = NormalizeText(heading.Text())
+		}
 
-	// Check if HTML page is empty
-	if strHTML == "" {
-		return Article{}, fmt.Errorf("HTML is empty")
+		// Siblings are not merged, since they're likely the other articles
+		articleContent := goquery.NewDocumentFromNode(&html.Node{
+			Type:     html.ElementNode,
+			Data:     "div",
+			DataAtom: atom.Div,
+		}).Selection
+		articleContent.AppendSelection(candidate.node)
+
+		r.prepArticle(articleContent)
+		articles = append(articles, r.newArticle(ctx, meta, articleContent))
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 	}
 
-	// Create goquery document
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(strHTML))
-	if err != nil {
-		return Article{}, err
+	if len(articles) == 0 {
+		return nil, ErrNoContent
 	}
 
+	return articles, nil
+}
+
+// scoreDocument prepares and scores the document, then returns at most n
+// candidates ordered by their score.
+func scoreDocument(doc *goquery.Document, parsedURL *nurl.URL, opts Options, n int) ([]Candidate, error) {
 	// Create new readability
 	r := readability{
 		url:        parsedURL,
-		candidates: make(map[string]candidateItem),
+		opts:       opts,
+		candidates: make(map[*html.Node]*candidateItem),
+	}
+
+	// Make sure the page is not too big to process
+	if opts.MaxElements > 0 && doc.Find("*").Length() > opts.MaxElements {
+		return nil, ErrTooManyElements
 	}
 
-	// Prepare document and fetch content
 	r.prepareDocument(doc)
-	contentNode := r.getArticleContent(doc)
+	r.scoreCandidates(doc, true)
+
+	// Collect the candidates in document order, so the ones with
+	// the same score are ordered consistently
+	var candidates []Candidate
+	doc.Find("*").Each(func(_ int, s *goquery.Selection) {
+		candidate, ok := r.candidates[s.Get(0)]
+		if !ok {
+			return
+		}
 
-	// Get article metadata
-	meta := r.getArticleMetadata(doc)
-	meta.MinReadTime, meta.MaxReadTime = r.estimateReadTime(contentNode)
+		candidates = append(candidates, Candidate{
+			TagName:     r.getTagName(s),
+			ClassName:   s.AttrOr("class", ""),
+			ID:          s.AttrOr("id", ""),
+			Score:       candidate.score,
+			LinkDensity: r.getLinkDensity(s),
+		})
+	})
 
-	// Get text and HTML from content
-	textContent := ""
-	htmlContent := ""
-	if contentNode != nil {
-		// If we haven't found an excerpt in the article's metadata, use the first paragraph
-		if meta.Excerpt == "" {
-			p := contentNode.Find("p").First().Text()
-			meta.Excerpt = normalizeText(p)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+
+	if n > 0 && len(candidates) > n {
+		candidates = candidates[:n]
+	}
+
+	return candidates, nil
+}
+
+// selectArticleCandidates returns at most n candidates with the highest score
+// that don't overlap each other. Candidates that contain several articles,
+// e.g. the <main> of an index page, are skipped as well.
+func (r *readability) selectArticleCandidates(doc *goquery.Document, n int) []*candidateItem {
+	// Collect the candidates in document order, so the ones with
+	// the same score are ordered consistently
+	var qualified []*candidateItem
+	doc.Find("*").Each(func(_ int, s *goquery.Selection) {
+		candidate, ok := r.candidates[s.Get(0)]
+		if ok && candidate.score >= minArticleScore &&
+			StrLen(NormalizeText(candidate.node.Text())) >= minArticleLength {
+			qualified = append(qualified, candidate)
 		}
+	})
 
-		// Get content text and HTML
-		textContent = r.getTextContent(contentNode)
-		htmlContent = r.getHTMLContent(contentNode)
+	sort.SliceStable(qualified, func(i, j int) bool {
+		return qualified[i].score > qualified[j].score
+	})
+
+	overlaps := func(a, b *candidateItem) bool {
+		return isAncestorNode(a.node.Get(0), b.node.Get(0)) || isAncestorNode(b.node.Get(0), a.node.Get(0))
 	}
 
-	article := Article{
-		URL:        parsedURL.String(),
-		Meta:       meta,
-		Content:    textContent,
-		RawContent: htmlContent,
+	var selected []*candidateItem
+	for _, candidate := range qualified {
+		if n > 0 && len(selected) >= n {
+			break
+		}
+
+		// Skip candidate that overlaps with the selected one
+		overlapped := false
+		for _, other := range selected {
+			if overlaps(candidate, other) {
+				overlapped = true
+				break
+			}
+		}
+
+		if overlapped {
+			continue
+		}
+
+		// Skip candidate that contains at least two separated candidates
+		var descendants []*candidateItem
+		for _, other := range qualified {
+			if other == candidate || !isAncestorNode(candidate.node.Get(0), other.node.Get(0)) {
+				continue
+			}
+
+			separated := true
+			for _, descendant := range descendants {
+				if overlaps(other, descendant) {
+					separated = false
+					break
+				}
+			}
+
+			if separated {
+				descendants = append(descendants, other)
+			}
+		}
+
+		if len(descendants) >= 2 {
+			continue
+		}
+
+		selected = append(selected, candidate)
 	}
 
-	return article, nil
+	return selected
 }
 
 // Prepare the HTML document for readability to scrape it.
 // This includes things like stripping Javascript, CSS, and handling terrible markup.
 func (r *readability) prepareDocument(doc *goquery.Document) {
-	// Remove tags
+	r.setBaseURL(doc)
+
+	// Remove tags, but recover images inside noscript first
+	r.unwrapNoscriptImages(doc)
 	doc.Find("script").Remove()
 	doc.Find("noscript").Remove()
 	doc.Find("style").Remove()
-	doc.Find("link").Remove()
+
+	// Only stylesheets are removed from <link>, since the other ones like
+	// canonical URL and icon are part of the page metadata
+	doc.Find("link").Each(func(_ int, link *goquery.Selection) {
+		for _, rel := range strings.Fields(strings.ToLower(link.AttrOr("rel", ""))) {
+			if rel == "stylesheet" {
+				link.Remove()
+				return
+			}
+		}
+	})
+
+	// Remove elements that known to be junk by the user
+	for _, selector := range r.opts.RemoveSelectors {
+		doc.Find(selector).Remove()
+	}
+
+	// Remove elements that hidden by their inline style
+	doc.Find("[style]").Each(func(_ int, s *goquery.Selection) {
+		if isHiddenByStyle(s.AttrOr("style", "")) {
+			s.Remove()
+		}
+	})
+
+	// Convert AMP elements to their standard counterparts, so images and videos
+	// in AMP pages are handled like the ones in regular pages
+	doc.Find("amp-img, amp-video, amp-audio").Each(func(_ int, s *goquery.Selection) {
+		node := s.Get(0)
+		node.Data = strings.TrimPrefix(node.Data, "amp-")
+		node.DataAtom = atom.Lookup([]byte(node.Data))
+
+		// Image is void element, so its fallback children must be removed
+		if node.DataAtom == atom.Img {
+			s.Empty()
+		}
+	})
+
+	// Collapsible details are opened, since its content is part of article
+	doc.Find("details").Each(func(_ int, details *goquery.Selection) {
+		details.SetAttr("open", "")
+		r.wrapDetailsText(details.Get(0))
+	})
+
+	// Remove the redundant wrappers, so the content isn't nested too deep
+	if r.opts.FlattenWrappers {
+		r.flattenWrappers(doc)
+	}
+
+	// Replace successive <br> with paragraph
+	r.replaceBrs(doc)
 
 	// Replace font tags to span
 	doc.Find("font").Each(func(_ int, font *goquery.Selection) {
@@ -160,10 +1276,265 @@ func (r *readability) prepareDocument(doc *goquery.Document) {
 	})
 }
 
+// Replaces 2 or more successive <br> elements with a single <p>, then moves
+// the phrasing content that follows into it. Whitespace between <br> elements
+// are ignored. For example:
+//
+//	<div>foo<br>bar<br> <br><br>abc</div>
+//
+// will become:
+//
+//	<div>foo<br>bar<p>abc</p></div>
+func (r *readability) replaceBrs(doc *goquery.Document) {
+	doc.Find("br").Each(func(_ int, br *goquery.Selection) {
+		// Skip <br> that already removed as part of previous chain
+		brNode := br.Get(0)
+		parent := brNode.Parent
+		if parent == nil {
+			return
+		}
+
+		// Remove the <br> siblings that follow this <br>
+		replaced := false
+		next := brNode.NextSibling
+		for {
+			next = nextNode(next)
+			if next == nil || next.DataAtom != atom.Br {
+				break
+			}
+
+			replaced = true
+			sibling := next.NextSibling
+			parent.RemoveChild(next)
+			next = sibling
+		}
+
+		// If we removed a <br> chain, replace the remaining <br> with a <p>,
+		// and add all sibling nodes as children of the <p> until we hit
+		// another <br> chain or a block element.
+		if !replaced {
+			return
+		}
+
+		p := &html.Node{Type: html.ElementNode, Data: "p", DataAtom: atom.P}
+		parent.InsertBefore(p, brNode)
+		parent.RemoveChild(brNode)
+
+		for next = p.NextSibling; next != nil; {
+			// If we've hit another <br><br>, we're done adding children to this <p>
+			if next.DataAtom == atom.Br {
+				if nextElem := nextNode(next.NextSibling); nextElem != nil && nextElem.DataAtom == atom.Br {
+					break
+				}
+			}
+
+			if !isPhrasingContent(next) {
+				break
+			}
+
+			sibling := next.NextSibling
+			parent.RemoveChild(next)
+			p.AppendChild(next)
+			next = sibling
+		}
+
+		for p.LastChild != nil && isWhitespaceNode(p.LastChild) {
+			p.RemoveChild(p.LastChild)
+		}
+
+		// Paragraph can't be nested, so its parent is converted to <div>
+		if parent.DataAtom == atom.P {
+			parent.Data = "div"
+			parent.DataAtom = atom.Div
+		}
+	})
+}
+
+// Wraps the text inside <details> that isn't part of its <summary> with <p>,
+// so the answers in FAQ are scored like the other paragraphs. For example:
+//
+//	<details><summary>foo</summary>bar <b>baz</b></details>
+//
+// will become:
+//
+//	<details><summary>foo</summary><p>bar <b>baz</b></p></details>
+func (r *readability) wrapDetailsText(details *html.Node) {
+	var p *html.Node
+	for c := details.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch {
+		case c.DataAtom == atom.Summary || !isPhrasingContent(c):
+			p = nil
+		case p != nil:
+			details.RemoveChild(c)
+			p.AppendChild(c)
+		case !isWhitespaceNode(c):
+			p = &html.Node{Type: html.ElementNode, Data: "p", DataAtom: atom.P}
+			details.InsertBefore(p, c)
+			details.RemoveChild(c)
+			p.AppendChild(c)
+		}
+		c = next
+	}
+}
+
+// Flattens the chain of <div> that only wraps another <div>, so
+//
+//	<div><div class="content"><div>foo</div></div></div>
+//
+// will become:
+//
+//	<div class="content">foo</div>
+//
+// The wrapper is only merged if one of them doesn't have any attribute, so
+// the class names and ids used for scoring are never lost.
+func (r *readability) flattenWrappers(doc *goquery.Document) {
+	divs := doc.Find("div").Nodes
+	for i := len(divs) - 1; i >= 0; i-- {
+		div := divs[i]
+		child := nextNode(div.FirstChild)
+		if div.Parent == nil || child == nil || child.DataAtom != atom.Div || nextNode(child.NextSibling) != nil {
+			continue
+		}
+
+		switch {
+		case len(child.Attr) == 0:
+			for grandChild := child.FirstChild; grandChild != nil; grandChild = child.FirstChild {
+				child.RemoveChild(grandChild)
+				div.InsertBefore(grandChild, child)
+			}
+			div.RemoveChild(child)
+		case len(div.Attr) == 0:
+			div.RemoveChild(child)
+			div.Parent.InsertBefore(child, div)
+			div.Parent.RemoveChild(div)
+		}
+	}
+}
+
+// Get the URL of site icon declared in the page. When there are several icons,
+// the one with the highest resolution is used. If there are no icon declared,
+// the default /favicon.ico is assumed.
+func (r *readability) getFavicon(doc *goquery.Document) string {
+	favicon, bestSize := "", -1
+	doc.Find("link[rel][href]").Each(func(_ int, link *goquery.Selection) {
+		isIcon := false
+		for _, rel := range strings.Fields(strings.ToLower(link.AttrOr("rel", ""))) {
+			if rel == "icon" || rel == "apple-touch-icon" || rel == "apple-touch-icon-precomposed" {
+				isIcon = true
+				break
+			}
+		}
+
+		href := strings.TrimSpace(link.AttrOr("href", ""))
+		if !isIcon || href == "" {
+			return
+		}
+
+		// Size is declared as "32x32", or "any" for scalable icon. If there are
+		// several sizes, the largest one is used.
+		size := 0
+		for _, declared := range strings.Fields(strings.ToLower(link.AttrOr("sizes", ""))) {
+			if declared == "any" {
+				size = math.MaxInt32
+				break
+			}
+
+			if dimension := strings.SplitN(declared, "x", 2); len(dimension) == 2 {
+				width, _ := strconv.Atoi(dimension[0])
+				height, _ := strconv.Atoi(dimension[1])
+				if width*height > size {
+					size = width * height
+				}
+			}
+		}
+
+		if size > bestSize {
+			favicon, bestSize = href, size
+		}
+	})
+
+	if favicon == "" {
+		favicon = "/favicon.ico"
+	}
+
+	return r.toAbsoluteURI(favicon)
+}
+
+// Find all <noscript> that contain a single image, and use it to replace
+// the previous element if it's a single image as well. Some sites use lazy
+// loading with placeholder image, and put the real image inside noscript.
+func (r *readability) unwrapNoscriptImages(doc *goquery.Document) {
+	doc.Find("noscript").Each(func(_ int, noscript *goquery.Selection) {
+		prevElement := noscript.Prev()
+		if prevElement.Length() == 0 || !r.isSingleImage(prevElement) {
+			return
+		}
+
+		// The content of noscript is parsed as raw text, so it must be parsed
+		// again to find the image inside it
+		tmpDoc, err := goquery.NewDocumentFromReader(strings.NewReader(noscript.Text()))
+		if err != nil {
+			return
+		}
+
+		tmpBody := tmpDoc.Find("body")
+		if tmpBody.Children().Length() != 1 || !r.isSingleImage(tmpBody) {
+			return
+		}
+
+		prevElement.ReplaceWithSelection(tmpBody.Children())
+	})
+}
+
+// Check if node is image, or if node contains exactly only one image
+// whether as a direct child or as its descendants.
+func (r *readability) isSingleImage(s *goquery.Selection) bool {
+	if r.getTagName(s) == "img" {
+		return true
+	}
+
+	children := s.Children()
+	if children.Length() != 1 || strings.TrimSpace(s.Text()) != "" {
+		return false
+	}
+
+	return r.isSingleImage(children)
+}
+
+// Check if the page has paywall element, i.e. element which class name or id
+// looks like paywall or metered access. Subscription prompt is not checked
+// here since it's also used by newsletter forms, and the ones inside header,
+// footer, navigation and sidebar are ignored as they exist in every page.
+func (r *readability) hasPaywall(doc *goquery.Document) bool {
+	found := false
+	doc.Find("[class],[id]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		matchString := s.AttrOr("class", "") + " " + s.AttrOr("id", "")
+		if paywallMarkers.MatchString(matchString) && s.Closest("header,footer,nav,aside").Length() == 0 {
+			found = true
+		}
+		return !found
+	})
+
+	return found
+}
+
+// Check if the content ends with a short paragraph that asks the reader to
+// subscribe, which usually means the rest of article is behind paywall.
+func (r *readability) hasSubscribePrompt(content *goquery.Selection) bool {
+	lastText := NormalizeText(content.Find("p").Last().Text())
+	return StrLen(lastText) <= 200 && subscribeCTA.MatchString(lastText)
+}
+
 // Attempts to get metadata for the article.
 func (r *readability) getArticleMetadata(doc *goquery.Document) Metadata {
 	metadata := Metadata{}
 	mapAttribute := make(map[string]string)
+	schema := r.getJSONLD(doc)
+
+	// Metadata is read before the document is prepared, so the base URL
+	// must be set here as well for the image and favicon URLs
+	r.setBaseURL(doc)
 
 	doc.Find("meta").Each(func(_ int, meta *goquery.Selection) {
 		metaName, _ := meta.Attr("name")
@@ -174,18 +1545,26 @@ func (r *readability) getArticleMetadata(doc *goquery.Document) Metadata {
 		metaProperty = strings.TrimSpace(metaProperty)
 		metaContent = strings.TrimSpace(metaContent)
 
-		// Fetch author name
+		// Fetch tags, which may be declared several times
+		if metaProperty == "article:tag" || metaName == "keywords" || metaName == "news_keywords" {
+			metadata.Tags = append(metadata.Tags, strings.Split(metaContent, ",")...)
+			return
+		}
+
+		// Fetch author names, which may be declared several times
 		if strings.Contains(metaName+metaProperty, "author") {
-			metadata.Author = metaContent
+			metadata.Authors = append(metadata.Authors, metaContent)
 			return
 		}
 
 		// Fetch description and title
 		if metaName == "title" ||
+			metaName == "date" ||
 			metaName == "description" ||
 			metaName == "twitter:title" ||
 			metaName == "twitter:image" ||
-			metaName == "twitter:description" {
+			metaName == "twitter:description" ||
+			metaName == "application-name" {
 			if _, exist := mapAttribute[metaName]; !exist {
 				mapAttribute[metaName] = metaContent
 			}
@@ -194,7 +1573,11 @@ func (r *readability) getArticleMetadata(doc *goquery.Document) Metadata {
 
 		if metaProperty == "og:description" ||
 			metaProperty == "og:image" ||
-			metaProperty == "og:title" {
+			metaProperty == "og:title" ||
+			metaProperty == "og:site_name" ||
+			metaProperty == "og:url" ||
+			metaProperty == "og:locale" ||
+			metaProperty == "article:published_time" {
 			if _, exist := mapAttribute[metaProperty]; !exist {
 				mapAttribute[metaProperty] = metaContent
 			}
@@ -207,23 +1590,50 @@ func (r *readability) getArticleMetadata(doc *goquery.Document) Metadata {
 		metadata.Image = mapAttribute["og:image"]
 	} else if _, exist := mapAttribute["twitter:image"]; exist {
 		metadata.Image = mapAttribute["twitter:image"]
+	} else if schema.Image != "" {
+		metadata.Image = schema.Image
 	}
 
-	if metadata.Image != "" && strings.HasPrefix(metadata.Image, "//") {
-		metadata.Image = "http:" + metadata.Image
+	// Make sure image URL is absolute, including the protocol-relative
+	// one which will use the scheme of page URL.
+	if metadata.Image != "" {
+		metadata.Image = r.toAbsoluteURI(metadata.Image)
 	}
 
 	// Set final description
+	metadata.MetaDescription = mapAttribute["description"]
 	if _, exist := mapAttribute["description"]; exist {
 		metadata.Excerpt = mapAttribute["description"]
 	} else if _, exist := mapAttribute["og:description"]; exist {
 		metadata.Excerpt = mapAttribute["og:description"]
 	} else if _, exist := mapAttribute["twitter:description"]; exist {
 		metadata.Excerpt = mapAttribute["twitter:description"]
+	} else if schema.Description != "" {
+		metadata.Excerpt = schema.Description
+	}
+
+	// Set final authors. JSON-LD is preferred since author in meta tags
+	// is often an URL to the author's profile instead of a name.
+	if len(schema.Authors) > 0 {
+		metadata.Authors = schema.Authors
+	}
+
+	// Some sites put several authors in one declaration, so split them
+	var authors []string
+	for _, author := range metadata.Authors {
+		authors = append(authors, splitNames(author)...)
+	}
+
+	metadata.Authors = uniqueTexts(authors)
+	metadata.Author = strings.Join(metadata.Authors, ", ")
+
+	// Set final title. JSON-LD headline is preferred since it's
+	// usually cleaner than the title tag.
+	metadata.Title = schema.Headline
+	if metadata.Title == "" {
+		metadata.Title = r.getArticleTitle(doc)
 	}
 
-	// Set final title
-	metadata.Title = r.getArticleTitle(doc)
 	if metadata.Title == "" {
 		if _, exist := mapAttribute["og:title"]; exist {
 			metadata.Title = mapAttribute["og:title"]
@@ -232,6 +1642,87 @@ func (r *readability) getArticleMetadata(doc *goquery.Document) Metadata {
 		}
 	}
 
+	// Set final site name. If the page doesn't declare it,
+	// use the registrable domain of the page URL.
+	if _, exist := mapAttribute["og:site_name"]; exist {
+		metadata.SiteName = mapAttribute["og:site_name"]
+	} else if _, exist := mapAttribute["application-name"]; exist {
+		metadata.SiteName = mapAttribute["application-name"]
+	} else if schema.Publisher != "" {
+		metadata.SiteName = schema.Publisher
+	} else {
+		hostname := r.url.Hostname()
+		if domain, err := publicsuffix.EffectiveTLDPlusOne(hostname); err == nil {
+			metadata.SiteName = domain
+		} else {
+			metadata.SiteName = hostname
+		}
+	}
+
+	// Set final canonical URL. When both exist, rel=canonical is preferred
+	// since og:url is sometimes left pointing to the home page.
+	canonicalURL := strings.TrimSpace(doc.Find(`link[rel="canonical"]`).First().AttrOr("href", ""))
+	if canonicalURL == "" {
+		canonicalURL = mapAttribute["og:url"]
+	}
+
+	if canonicalURL != "" {
+		metadata.CanonicalURL = r.toAbsoluteURI(canonicalURL)
+	}
+
+	// Set final next page, for article which split into several pages
+	metadata.NextPageURL = r.getNextPageURL(doc)
+
+	// Set final tags, including the ones linked using rel=tag
+	doc.Find("a[rel]").Each(func(_ int, link *goquery.Selection) {
+		for _, rel := range strings.Fields(strings.ToLower(link.AttrOr("rel", ""))) {
+			if rel == "tag" {
+				metadata.Tags = append(metadata.Tags, link.Text())
+				break
+			}
+		}
+	})
+	metadata.Tags = uniqueTexts(metadata.Tags)
+
+	// Set final favicon
+	metadata.Favicon = r.getFavicon(doc)
+
+	// Check if the article is behind paywall. It's checked before the document
+	// prepared, since paywall is often hidden until it's shown by script.
+	metadata.Truncated = schema.Paywalled || r.hasPaywall(doc)
+
+	// Set final language from the one declared by the page. If it's not
+	// declared, later it will be detected from the article content.
+	metadata.Language = strings.TrimSpace(doc.Find("html").First().AttrOr("lang", ""))
+	if metadata.Language == "" {
+		metadata.Language = strings.Replace(mapAttribute["og:locale"], "_", "-", -1)
+	}
+
+	// Set final publish time. JSON-LD is preferred since it's the most
+	// structured, followed by meta tags and the first <time> element.
+	publishTimes := []string{
+		schema.DatePublished,
+		mapAttribute["article:published_time"],
+		mapAttribute["date"],
+		strings.TrimSpace(doc.Find("time[datetime]").First().AttrOr("datetime", "")),
+	}
+
+	for _, publishTime := range publishTimes {
+		if publishTime == "" {
+			continue
+		}
+
+		if metadata.RawPublishedTime == "" {
+			metadata.RawPublishedTime = publishTime
+		}
+
+		if parsedTime, ok := parseTime(publishTime); ok {
+			metadata.PublishedTime = parsedTime
+			metadata.RawPublishedTime = publishTime
+			break
+		}
+	}
+
 	return metadata
 }
 
@@ -239,7 +1730,7 @@ func (r *readability) getArticleMetadata(doc *goquery.Document) Metadata {
 func (r *readability) getArticleTitle(doc *goquery.Document) string {
 	// Get title tag
 	title := doc.Find("title").First().Text()
-	title = normalizeText(title)
+	title = NormalizeText(title)
 	originalTitle := title
 
 	// Create list of separator
@@ -293,10 +1784,10 @@ func (r *readability) getArticleTitle(doc *goquery.Document) string {
 				}
 			}
 		}
-	} else if strLen(title) > 150 || strLen(title) < 15 {
+	} else if StrLen(title) > 150 || StrLen(title) < 15 {
 		hOne := doc.Find("h1").First()
 		if hOne != nil {
-			title = normalizeText(hOne.Text())
+			title = NormalizeText(hOne.Text())
 		}
 	}
 
@@ -313,29 +1804,147 @@ func (r *readability) getArticleTitle(doc *goquery.Document) string {
 	return title
 }
 
-// Using a variety of metrics (content score, classname, element types), find the content that is
-// most likely to be the stuff a user wants to read. Then return it wrapped up in a div.
+// Find the content that is most likely to be the stuff a user wants to read.
+// If nothing found, retry without removing the unlikely candidates since the
+// content might be marked up with an unlikely class name or id.
 func (r *readability) getArticleContent(doc *goquery.Document) *goquery.Selection {
+	// Use the element that known to contain the content if it's specified
+	if content := r.getPreferredContent(doc); content != nil {
+		return content
+	}
+
+	// Keep a copy of the page since grabArticle modifies the document.
+	// Copying the nodes is much cheaper than rendering and parsing the page.
+	retryDoc := goquery.NewDocumentFromNode(cloneNode(doc.Get(0)))
+	content := r.grabArticle(doc, true)
+	if content != nil && !r.isContentTooShort(content) {
+		return content
+	}
+
+	// Retry without removing unlikely candidates, and use the result
+	// if it's longer than the first attempt
+	retryContent := r.grabArticle(retryDoc, false)
+	if content == nil || (retryContent != nil &&
+		StrLen(NormalizeText(retryContent.Text())) > StrLen(NormalizeText(content.Text()))) {
+		return retryContent
+	}
+
+	return content
+}
+
+// Get the content from the elements matched by PreferSelector, skipping the
+// scoring. Nil is returned if nothing matched or if the matched elements don't
+// have enough text, so the content will be searched as usual.
+func (r *readability) getPreferredContent(doc *goquery.Document) *goquery.Selection {
+	if r.opts.PreferSelector == "" {
+		return nil
+	}
+
+	// Skip the elements nested in the other match, since they're already
+	// included along with their ancestor
+	matches := doc.Find(r.opts.PreferSelector)
+	matches = matches.FilterFunction(func(_ int, s *goquery.Selection) bool {
+		for _, n := range matches.Nodes {
+			if isAncestorNode(n, s.Get(0)) {
+				return false
+			}
+		}
+		return true
+	})
+
+	minLength := minArticleLength
+	if r.opts.MinContentLength > minLength {
+		minLength = r.opts.MinContentLength
+	}
+
+	if matches.Length() == 0 || StrLen(NormalizeText(matches.Text())) < minLength {
+		return nil
+	}
+
+	if r.opts.Debug {
+		r.debug = &DebugInfo{
+			TagName:     r.getTagName(matches.First()),
+			LinkDensity: r.getLinkDensity(matches),
+		}
+	}
+
+	articleContent := goquery.NewDocumentFromNode(&html.Node{
+		Type:     html.ElementNode,
+		Data:     "div",
+		DataAtom: atom.Div,
+	}).Selection
+	articleContent.AppendSelection(matches)
+
+	r.prepArticle(articleContent)
+	return articleContent
+}
+
+// Prepare the nodes in document, then score each paragraph and give the score
+// to its ancestors. The scored ancestors are saved as candidates.
+func (r *readability) scoreCandidates(doc *goquery.Document, stripUnlikelys bool) {
 	// First, node prepping. Trash nodes that look cruddy (like ones with the
 	// class name "comment", etc), and turn divs into P tags where they have been
 	// used inappropriately (as in, where they contain no other block level elements.)
+	rxUnlikelyCandidates := unlikelyCandidates
+	if r.opts.UnlikelyCandidates != nil {
+		rxUnlikelyCandidates = r.opts.UnlikelyCandidates
+	}
+
+	rxOkMaybeItsACandidate := okMaybeItsACandidate
+	if r.opts.OkMaybeItsACandidate != nil {
+		rxOkMaybeItsACandidate = r.opts.OkMaybeItsACandidate
+	}
+
 	doc.Find("*").Each(func(i int, s *goquery.Selection) {
-		matchString := s.AttrOr("class", "") + " " + s.AttrOr("id", "")
+		// Remove hidden elements, since they're not visible to the reader
+		if !r.isProbablyVisible(s) {
+			s.Remove()
+			return
+		}
+
+		// Build the string for matching class and id once. Most elements don't
+		// have any of them, in which case the regexes don't need to be run.
+		className, id := s.AttrOr("class", ""), s.AttrOr("id", "")
+		hasMatchString := className != "" || id != ""
+		matchString := className + " " + id
+
+		// Remove comment sections, they're not part of the article
+		if r.isCommentWidget(s, hasMatchString, matchString) {
+			s.Remove()
+			return
+		}
+
+		// If byline, remove this element. Keep its text though, since it can be
+		// used as author when the page doesn't declare it in metadata.
+		if r.isByline(s, hasMatchString, matchString) {
+			if text := r.getBylineText(s); r.byline == "" && text != "" && StrLen(text) < 100 {
+				r.byline = text
+			}
 
-		// If byline, remove this element
-		if rel := s.AttrOr("rel", ""); rel == "author" || byline.MatchString(matchString) {
 			s.Remove()
 			return
 		}
 
 		// Remove unlikely candidates
-		if unlikelyCandidates.MatchString(matchString) &&
-			!okMaybeItsACandidate.MatchString(matchString) &&
+		if stripUnlikelys && hasMatchString && rxUnlikelyCandidates.MatchString(matchString) &&
+			!rxOkMaybeItsACandidate.MatchString(matchString) &&
 			!s.Is("body") && !s.Is("a") {
 			s.Remove()
 			return
 		}
 
+		// Remove elements which role indicates they're not part of the content
+		if _, unlikely := unlikelyRoles[s.AttrOr("role", "")]; stripUnlikelys && unlikely {
+			s.Remove()
+			return
+		}
+
+		// Remove widgets that identify themselves using data attributes
+		if stripUnlikelys && r.isUnlikelyWidget(s) {
+			s.Remove()
+			return
+		}
+
 		if unlikelyElements.MatchString(r.getTagName(s)) {
 			s.Remove()
 			return
@@ -359,11 +1968,23 @@ func (r *readability) getArticleContent(doc *goquery.Document) *goquery.Selectio
 	// Loop through all paragraphs, and assign a score to them based on how content-y they look.
 	// Then add their score to their parent node.
 	// A score is determined by things like number of commas, class names, etc. Maybe eventually link density.
-	r.candidates = make(map[string]candidateItem)
-	doc.Find("p").Each(func(i int, s *goquery.Selection) {
-		// If this paragraph is less than 25 characters, don't even count it.
-		innerText := normalizeText(s.Text())
-		if strLen(innerText) < 25 {
+	minParagraphLength := r.opts.MinParagraphLength
+	if minParagraphLength <= 0 {
+		minParagraphLength = defaultMinParagraphLength
+	}
+
+	r.candidates = make(map[*html.Node]*candidateItem)
+	doc.Find("p,figure").Each(func(i int, s *goquery.Selection) {
+		// Figure is counted by its media instead of its caption, so photo essays
+		// with short captions are still counted as content.
+		isFigure := s.Is("figure")
+		if isFigure && s.Find("img,picture,video").Length() == 0 {
+			return
+		}
+
+		// If this paragraph is too short (by default less than 25 characters), don't even count it.
+		innerText := NormalizeText(s.Text())
+		if !isFigure && StrLen(innerText) < minParagraphLength {
 			return
 		}
 
@@ -378,11 +1999,10 @@ func (r *readability) getArticleContent(doc *goquery.Document) *goquery.Selectio
 		contentScore := 1.0
 
 		// Add points for any commas within this paragraph.
-		contentScore += float64(strings.Count(innerText, ","))
-		contentScore += float64(strings.Count(innerText, "，"))
+		contentScore += float64(countCommas(innerText))
 
 		// For every 100 characters in this paragraph, add another point. Up to 3 points.
-		contentScore += math.Min(math.Floor(float64(strLen(innerText)/100)), 3)
+		contentScore += math.Min(math.Floor(float64(StrLen(innerText)/100)), 3)
 
 		// Initialize and score ancestors.
 		for level, ancestor := range ancestors {
@@ -399,31 +2019,41 @@ func (r *readability) getArticleContent(doc *goquery.Document) *goquery.Selectio
 				scoreDivider = level * 3
 			}
 
-			ancestorHash := hashStr(ancestor)
-			if _, ok := r.candidates[ancestorHash]; !ok {
-				r.candidates[ancestorHash] = r.initializeNodeScore(ancestor)
+			// Use the node itself as key, since different nodes could have same HTML
+			ancestorNode := ancestor.Nodes[0]
+			candidate, ok := r.candidates[ancestorNode]
+			if !ok {
+				candidate = r.initializeNodeScore(ancestor)
+				r.candidates[ancestorNode] = candidate
 			}
 
-			candidate := r.candidates[ancestorHash]
 			candidate.score += contentScore / float64(scoreDivider)
-			r.candidates[ancestorHash] = candidate
 		}
 	})
 
+	// Scale the score of each candidate by its link density, since the content
+	// shouldn't be consisted of links
+	for _, candidate := range r.candidates {
+		candidate.score = candidate.score * (1 - r.getLinkDensity(candidate.node))
+	}
+}
+
+// Check if the text of content is shorter than the minimum length in options.
+func (r *readability) isContentTooShort(content *goquery.Selection) bool {
+	return r.opts.MinContentLength > 0 && StrLen(NormalizeText(content.Text())) < r.opts.MinContentLength
+}
+
+// Using a variety of metrics (content score, classname, element types), find the content that is
+// most likely to be the stuff a user wants to read. Then return it wrapped up in a div.
+func (r *readability) grabArticle(doc *goquery.Document, stripUnlikelys bool) *goquery.Selection {
+	r.scoreCandidates(doc, stripUnlikelys)
+
 	// After we've calculated scores, loop through all of the possible
 	// candidate nodes we found and find the one with the highest score.
 	var topCandidate *candidateItem
-	for hash, candidate := range r.candidates {
-		candidate.score = candidate.score * (1 - r.getLinkDensity(candidate.node))
-		r.candidates[hash] = candidate
-
+	for _, candidate := range r.candidates {
 		if topCandidate == nil || candidate.score > topCandidate.score {
-			if topCandidate == nil {
-				topCandidate = new(candidateItem)
-			}
-
-			topCandidate.score = candidate.score
-			topCandidate.node = candidate.node
+			topCandidate = candidate
 		}
 	}
 
@@ -432,15 +2062,168 @@ func (r *readability) getArticleContent(doc *goquery.Document) *goquery.Selectio
 		return nil
 	}
 
-	r.prepArticle(topCandidate.node)
-	return topCandidate.node
+	// Keep the detail of top candidate for debugging
+	if r.opts.Debug {
+		r.debug = &DebugInfo{
+			TagName:     r.getTagName(topCandidate.node),
+			Score:       topCandidate.score,
+			LinkDensity: r.getLinkDensity(topCandidate.node),
+			Candidates:  len(r.candidates),
+		}
+	}
+
+	// Look through the siblings of top candidate for content that might also be
+	// related, e.g. preambles, or article body that split by ads.
+	articleContent := r.mergeSiblings(topCandidate)
+	r.prepArticle(articleContent)
+	return articleContent
+}
+
+// Create a new node that contains the top candidate and its siblings that
+// look like part of the article, either because they have a high enough score,
+// share the same class name, or are paragraphs with enough non-link text.
+func (r *readability) mergeSiblings(topCandidate *candidateItem) *goquery.Selection {
+	articleContent := goquery.NewDocumentFromNode(&html.Node{
+		Type:     html.ElementNode,
+		Data:     "div",
+		DataAtom: atom.Div,
+	}).Selection
+
+	topNode := topCandidate.node.Nodes[0]
+	if topNode.Parent == nil {
+		articleContent.AppendSelection(topCandidate.node)
+		return articleContent
+	}
+
+	siblingScoreThreshold := math.Max(10, topCandidate.score*0.2)
+	topClassName := topCandidate.node.AttrOr("class", "")
+
+	// The siblings are listed first, since they will be moved while looping
+	parent := topCandidate.node.Parent()
+	siblings := parent.Children().Nodes
+
+	for _, sibling := range siblings {
+		appendSibling := sibling == topNode
+		siblingSelection := parent.Children().FilterNodes(sibling)
+
+		if !appendSibling {
+			contentBonus := 0.0
+
+			// Give a bonus if sibling nodes and top candidates have the same class name
+			if topClassName != "" && siblingSelection.AttrOr("class", "") == topClassName {
+				contentBonus += topCandidate.score * 0.2
+			}
+
+			if candidate, ok := r.candidates[sibling]; ok && candidate.score+contentBonus >= siblingScoreThreshold {
+				appendSibling = true
+			} else if sibling.DataAtom == atom.P {
+				linkDensity := r.getLinkDensity(siblingSelection)
+				nodeContent := NormalizeText(siblingSelection.Text())
+				nodeLength := StrLen(nodeContent)
+
+				if nodeLength > 80 && linkDensity < 0.25 {
+					appendSibling = true
+				} else if nodeLength < 80 && nodeLength > 0 && linkDensity == 0 &&
+					pIsSentence.MatchString(nodeContent) {
+					appendSibling = true
+				}
+			}
+		}
+
+		if appendSibling {
+			articleContent.AppendSelection(siblingSelection)
+		}
+	}
+
+	return articleContent
+}
+
+// Check if a node is a byline, i.e. it's marked as author using rel or itemprop
+// attribute, or its class name and id look like a byline.
+func (r *readability) isByline(s *goquery.Selection, hasMatchString bool, matchString string) bool {
+	if s.AttrOr("rel", "") == "author" || strings.Contains(s.AttrOr("itemprop", ""), "author") {
+		return true
+	}
+
+	return hasMatchString && byline.MatchString(matchString)
+}
+
+// Get the author name from byline element. The name inside the element that
+// marked as author is preferred, otherwise the "By" prefix is removed from
+// the whole text.
+func (r *readability) getBylineText(s *goquery.Selection) string {
+	if author := s.Find(`[rel="author"],[itemprop*="author"]`).First(); author.Length() > 0 {
+		if text := NormalizeText(author.Text()); text != "" {
+			return text
+		}
+	}
+
+	return bylinePrefix.ReplaceAllString(NormalizeText(s.Text()), "")
+}
+
+// Check if a node is a comment section from common comment platforms, judging
+// from its class name and id, or from the URL if it's an iframe.
+func (r *readability) isCommentWidget(s *goquery.Selection, hasMatchString bool, matchString string) bool {
+	if hasMatchString && commentWidgets.MatchString(matchString) {
+		return true
+	}
+
+	if r.getTagName(s) == "iframe" {
+		return commentFrames.MatchString(s.AttrOr("src", ""))
+	}
+
+	return false
+}
+
+// Check if a node is a widget like share bar or newsletter popup, judging
+// from its data attributes.
+func (r *readability) isUnlikelyWidget(s *goquery.Selection) bool {
+	for _, attrName := range widgetAttributes {
+		if unlikelyWidgets.MatchString(s.AttrOr(attrName, "")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check if a node is probably visible, i.e. not hidden using hidden attribute
+// or aria-hidden. Images used as fallback are considered visible though, since
+// some sites hide them from screen readers only. Elements hidden by inline
+// style are already removed in prepareDocument.
+func (r *readability) isProbablyVisible(s *goquery.Selection) bool {
+	if _, hidden := s.Attr("hidden"); hidden {
+		return false
+	}
+
+	if s.AttrOr("aria-hidden", "") == "true" {
+		return strings.Contains(s.AttrOr("class", ""), "fallback-image")
+	}
+
+	return true
 }
 
-// Check if a node is empty
+// Check if a node is empty, i.e. it doesn't have any text or media. Void
+// elements like <br> and <img> never have any content, so they're not
+// considered empty.
 func (r *readability) isElementEmpty(s *goquery.Selection) bool {
-	html, _ := s.Html()
-	html = strings.TrimSpace(html)
-	return html == ""
+	if _, isVoid := voidElements[s.Get(0).DataAtom]; isVoid {
+		return false
+	}
+
+	if strings.TrimSpace(s.Text()) != "" {
+		return false
+	}
+
+	// Empty cells are part of the table layout, removing them shifts the
+	// other cells into the wrong columns
+	if s.Is("td,th,tr") {
+		return false
+	}
+
+	// Image, video and the other media don't have any text, but they're
+	// the content, so element that is or contains them is never empty.
+	return !s.Is(mediaElements) && s.Find(mediaElements).Length() == 0
 }
 
 // Get tag name from a node
@@ -453,15 +2236,15 @@ func (r *readability) getTagName(s *goquery.Selection) string {
 
 func (r *readability) getNodeAncestors(node *goquery.Selection, maxDepth int) []*goquery.Selection {
 	ancestors := []*goquery.Selection{}
-	parent := *node
+	parent := node
 
 	for i := 0; i < maxDepth; i++ {
-		parent = *parent.Parent()
+		parent = parent.Parent()
 		if len(parent.Nodes) == 0 {
 			return ancestors
 		}
 
-		ancestors = append(ancestors, &parent)
+		ancestors = append(ancestors, parent)
 	}
 
 	return ancestors
@@ -480,7 +2263,7 @@ func (r *readability) hasAncestorTag(node *goquery.Selection, tag string) bool {
 
 // Initialize a node and checks the className/id for special names
 // to add to its score.
-func (r *readability) initializeNodeScore(node *goquery.Selection) candidateItem {
+func (r *readability) initializeNodeScore(node *goquery.Selection) *candidateItem {
 	contentScore := 0.0
 	switch r.getTagName(node) {
 	case "article":
@@ -498,13 +2281,17 @@ func (r *readability) initializeNodeScore(node *goquery.Selection) candidateItem
 	}
 
 	contentScore += r.getClassWeight(node)
-	return candidateItem{contentScore, node}
+	return &candidateItem{contentScore, node}
 }
 
 // Get an elements class/id weight. Uses regular expressions to tell if this
 // element looks good or bad.
 func (r *readability) getClassWeight(node *goquery.Selection) float64 {
 	weight := 0.0
+	if r.opts.DisableClassWeight {
+		return weight
+	}
+
 	if str, b := node.Attr("class"); b {
 		if negative.MatchString(str) {
 			weight -= 25
@@ -535,26 +2322,105 @@ func (r *readability) getLinkDensity(node *goquery.Selection) float64 {
 		return 0
 	}
 
-	textLength := strLen(normalizeText(node.Text()))
+	textLength := StrLen(NormalizeText(node.Text()))
 	if textLength == 0 {
 		return 0
 	}
 
-	linkLength := 0
+	// Links that only point to a fragment in the same page, e.g. footnotes,
+	// are likely part of the content, so they're weighted less
+	linkLength := 0.0
 	node.Find("a").Each(func(_ int, link *goquery.Selection) {
-		linkLength += strLen(link.Text())
+		coefficient := 1.0
+		if strings.HasPrefix(strings.TrimSpace(link.AttrOr("href", "")), "#") {
+			coefficient = 0.3
+		}
+
+		linkLength += float64(StrLen(link.Text())) * coefficient
+	})
+
+	return linkLength / float64(textLength)
+}
+
+// Remove blocks that mostly contain links to other articles in the same site,
+// e.g. "related articles" or "you might also like" lists.
+func (r *readability) removeRelatedLinks(content *goquery.Selection) {
+	content.Find("div, section, aside, nav, ul, ol").Each(func(_ int, block *goquery.Selection) {
+		if r.getLinkDensity(block) <= 0.5 {
+			return
+		}
+
+		nLinks, nArticleLinks := 0, 0
+		block.Find("a").Each(func(_ int, link *goquery.Selection) {
+			nLinks++
+			if r.isArticleLink(link.AttrOr("href", "")) {
+				nArticleLinks++
+			}
+		})
+
+		if nLinks >= 3 && float64(nArticleLinks) >= float64(nLinks)*0.75 {
+			block.Remove()
+		}
 	})
+}
+
+// Check if the URL points to other article in the same site as the page.
+func (r *readability) isArticleLink(href string) bool {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") || r.url == nil {
+		return false
+	}
+
+	linkURL, err := nurl.Parse(href)
+	if err != nil {
+		return false
+	}
+
+	linkURL = r.resolveReference(linkURL)
+	if strings.TrimPrefix(linkURL.Hostname(), "www.") != strings.TrimPrefix(r.url.Hostname(), "www.") ||
+		linkURL.Path == r.url.Path {
+		return false
+	}
 
-	return float64(linkLength) / float64(textLength)
+	return articlePath.MatchString(linkURL.Path)
 }
 
 // Prepare the article node for display. Clean out any inline styles,
-// iframes, forms, strip extraneous <p> tags, etc.
+// iframes, forms, strip extraneous <p> tags, etc. In light clean mode, only
+// the URLs are fixed, so the content keeps its original structure.
 func (r *readability) prepArticle(content *goquery.Selection) {
 	if content == nil {
 		return
 	}
 
+	// Keep the declared size of images before they're removed by cleanStyle
+	r.markImageSizes(content)
+
+	if !r.opts.LightClean {
+		r.cleanArticle(content)
+	}
+
+	// Fix lazy loaded images, then all relative URL
+	r.fixLazyImages(content)
+	r.fixResponsiveImages(content)
+	r.fixRelativeURIs(content)
+
+	if !r.opts.LightClean {
+		r.removeEmptyElements(content)
+	}
+
+	// Finally, make sure the content is safe to be rendered
+	if r.opts.Sanitize {
+		r.sanitize(content)
+	}
+}
+
+// Clean out the junk from the article content, i.e. styling attributes,
+// forms, unlikely headers and the blocks that don't look like content.
+func (r *readability) cleanArticle(content *goquery.Selection) {
+	// Find tables that contain data, so they can be kept
+	r.markDataTables(content)
+
 	// Remove styling attribute
 	r.cleanStyle(content)
 
@@ -570,12 +2436,11 @@ func (r *readability) prepArticle(content *goquery.Selection) {
 	// If there is only one h2 or h3 and its text content substantially equals article title,
 	// they are probably using it as a header and not a subheader,
 	// so remove it since we already extract the title separately.
-	if content.Find("h2").Length() == 1 {
-		r.clean(content, "h2")
-	}
-
-	if content.Find("h3").Length() == 1 {
-		r.clean(content, "h3")
+	for _, tag := range []string{"h2", "h3"} {
+		headings := content.Find(tag)
+		if headings.Length() == 1 && textSimilarity(r.title, headings.Text()) > 0.75 {
+			headings.Remove()
+		}
 	}
 
 	r.clean(content, "iframe")
@@ -591,17 +2456,34 @@ func (r *readability) prepArticle(content *goquery.Selection) {
 	r.cleanConditionally(content, "ul")
 	r.cleanConditionally(content, "div")
 
-	// Fix all relative URL
-	r.fixRelativeURIs(content)
+	// Remove "related articles" blocks that survived the cleaning above
+	r.removeRelatedLinks(content)
+}
 
-	// Last time, clean all empty tags and remove class name
+// Remove the empty elements and the class names from the article content.
+// The ids are only kept if they're targeted by links or they're headings.
+func (r *readability) removeEmptyElements(content *goquery.Selection) {
+	// Find ids that targeted by in-content links, e.g. footnotes,
+	// so they can be kept for the links to keep working.
+	linkTargets := make(map[string]struct{})
+	content.Find(`a[href^="#"]`).Each(func(_ int, link *goquery.Selection) {
+		if target := strings.TrimPrefix(link.AttrOr("href", ""), "#"); target != "" {
+			linkTargets[target] = struct{}{}
+		}
+	})
+
+	// Last time, clean all empty tags and remove class name.
 	content.Find("*").Each(func(_ int, s *goquery.Selection) {
 		if r.isElementEmpty(s) {
 			s.Remove()
 		}
 
-		s.RemoveAttr("class")
-		s.RemoveAttr("id")
+		// The ids of headings are kept as well, since they're used by table
+		// of contents to link to each section
+		r.removeAttr(s, "class")
+		if _, targeted := linkTargets[s.AttrOr("id", "")]; !targeted && !s.Is("h1,h2,h3,h4,h5,h6") {
+			r.removeAttr(s, "id")
+		}
 	})
 }
 
@@ -613,33 +2495,47 @@ func (r *readability) cleanStyle(s *goquery.Selection) {
 			return
 		}
 
-		s1.RemoveAttr("align")
-		s1.RemoveAttr("background")
-		s1.RemoveAttr("bgcolor")
-		s1.RemoveAttr("border")
-		s1.RemoveAttr("cellpadding")
-		s1.RemoveAttr("cellspacing")
-		s1.RemoveAttr("frame")
-		s1.RemoveAttr("hspace")
-		s1.RemoveAttr("rules")
-		s1.RemoveAttr("style")
-		s1.RemoveAttr("valign")
-		s1.RemoveAttr("vspace")
-		s1.RemoveAttr("onclick")
-		s1.RemoveAttr("onmouseover")
-		s1.RemoveAttr("border")
-		s1.RemoveAttr("style")
+		r.removeAttr(s1, "align", "background", "bgcolor", "border", "cellpadding",
+			"cellspacing", "frame", "hspace", "rules", "style", "valign", "vspace",
+			"onclick", "onmouseover")
 
 		if tagName != "table" && tagName != "th" && tagName != "td" &&
 			tagName != "hr" && tagName != "pre" {
-			s1.RemoveAttr("width")
-			s1.RemoveAttr("height")
+			r.removeAttr(s1, "width", "height")
 		}
 	})
 }
 
+// Remove the attributes from the node, except the ones that
+// must be preserved according to the options.
+func (r *readability) removeAttr(s *goquery.Selection, attrNames ...string) {
+	for _, attrName := range attrNames {
+		preserved := false
+		for _, preservedName := range r.opts.PreserveAttributes {
+			if strings.EqualFold(attrName, preservedName) {
+				preserved = true
+				break
+			}
+		}
+
+		if !preserved {
+			s.RemoveAttr(attrName)
+		}
+	}
+}
+
+// Get the pattern of URL of embedded content, e.g. video player, that should
+// be kept in the content.
+func (r *readability) allowedEmbeds() *regexp.Regexp {
+	if r.opts.AllowedEmbeds != nil {
+		return r.opts.AllowedEmbeds
+	}
+
+	return videos
+}
+
 // Clean a node of all elements of type "tag".
-// (Unless it's a youtube/vimeo video. People love movies.)
+// (Unless it's an allowed embed like youtube video. People love movies.)
 func (r *readability) clean(s *goquery.Selection, tag string) {
 	if s == nil {
 		return
@@ -656,82 +2552,323 @@ func (r *readability) clean(s *goquery.Selection, tag string) {
 			attributeValues += " " + attribute.Val
 		}
 
-		if isEmbed && videos.MatchString(attributeValues) {
-			return
+		if isEmbed && r.allowedEmbeds().MatchString(attributeValues) {
+			return
+		}
+
+		if isEmbed && r.allowedEmbeds().MatchString(target.Text()) {
+			return
+		}
+
+		target.Remove()
+	})
+}
+
+// Clean an element of all tags of type "tag" if they look fishy.
+// "Fishy" is an algorithm based on content length, classnames, link density, number of images & embeds, etc.
+func (r *readability) cleanConditionally(e *goquery.Selection, tag string) {
+	if e == nil {
+		return
+	}
+
+	isList := tag == "ul" || tag == "ol"
+	thresholds := r.opts.CleanThresholds.withDefaults()
+	articleLength := StrLen(NormalizeText(e.Text()))
+
+	e.Find(tag).Each(func(i int, node *goquery.Selection) {
+		// Don't touch anything inside code block, and keep the caption with its image
+		if r.hasAncestorTag(node, "pre") || r.hasAncestorTag(node, "code") ||
+			r.hasAncestorTag(node, "figcaption") {
+			return
+		}
+
+		// Keep data tables, including everything inside them
+		if r.hasDataTableAncestor(node) {
+			return
+		}
+
+		contentScore := 0.0
+		weight := r.getClassWeight(node)
+		if weight+contentScore < 0 {
+			node.Remove()
+			return
+		}
+
+		// If there are not very many commas, and the number of
+		// non-paragraph elements is more than paragraphs or other
+		// ominous signs, remove the element.
+		nodeText := NormalizeText(node.Text())
+		nCommas := countCommas(nodeText)
+		if nCommas < thresholds.MinCommas {
+			p := node.Find("p").Length()
+			img := node.Find("img").Length()
+			media := node.Find("video,audio").Length()
+			figure := node.Find("figure").Length()
+			li := node.Find("li").Length() - thresholds.ListItemAllowance
+			input := node.Find("input").Length()
+
+			embedCount := 0
+			node.Find("embed").Each(func(i int, embed *goquery.Selection) {
+				if !r.allowedEmbeds().MatchString(embed.AttrOr("src", "")) {
+					embedCount++
+				}
+			})
+
+			linkDensity := r.getLinkDensity(node)
+			contentLength := StrLen(NormalizeText(node.Text()))
+			haveToRemove := (!isList && li > p) ||
+				(img > 1 && float64(p+figure)/float64(img) < thresholds.MinParagraphImageRatio && !r.hasAncestorTag(node, "figure")) ||
+				(float64(input) > math.Floor(float64(p)/3)) ||
+				(!isList && contentLength < thresholds.MinContentLength && (img == 0 || img > 2) && media == 0 && !r.hasAncestorTag(node, "figure")) ||
+				(!isList && weight < 25 && linkDensity > thresholds.MaxLinkDensity) ||
+				(weight >= 25 && linkDensity > thresholds.MaxWeightedLinkDensity) ||
+				((embedCount == 1 && contentLength < thresholds.MinEmbedContentLength) || embedCount > 1)
+
+			// Keep the node if it has all the text of the article, since
+			// removing it would leave the article empty
+			if haveToRemove && contentLength < articleLength {
+				node.Remove()
+			}
+		}
+	})
+}
+
+// Save the width and height declared by each image in content.
+func (r *readability) markImageSizes(content *goquery.Selection) {
+	r.imageSizes = make(map[*html.Node]imageSize)
+	content.Find("img").Each(func(_ int, img *goquery.Selection) {
+		width, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(img.AttrOr("width", "")), "px"))
+		height, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(img.AttrOr("height", "")), "px"))
+		r.imageSizes[img.Nodes[0]] = imageSize{width, height}
+	})
+}
+
+// Find the image in content that most likely to be the hero image of article,
+// i.e. the largest one judging from its declared size. If none of the images
+// declare their size, the first one is used. Small images like icon are skipped.
+func (r *readability) getHeroImage(content *goquery.Selection) string {
+	heroImage, firstImage, largestArea := "", "", 0
+	content.Find("img").Each(func(_ int, img *goquery.Selection) {
+		src := strings.TrimSpace(img.AttrOr("src", ""))
+		size := r.imageSizes[img.Nodes[0]]
+		if src == "" || (size.width > 0 && size.width < minHeroImageSize) ||
+			(size.height > 0 && size.height < minHeroImageSize) {
+			return
+		}
+
+		if firstImage == "" {
+			firstImage = src
+		}
+
+		if area := size.width * size.height; area > largestArea {
+			heroImage, largestArea = src, area
+		}
+	})
+
+	if heroImage == "" {
+		heroImage = firstImage
+	}
+
+	return heroImage
+}
+
+// Find the tables that contain data instead of used for layout.
+// This is ported from _markDataTables in Readability.js.
+func (r *readability) markDataTables(content *goquery.Selection) {
+	r.dataTables = make(map[*html.Node]struct{})
+	content.Find("table").Each(func(_ int, table *goquery.Selection) {
+		if r.isDataTable(table) {
+			r.dataTables[table.Nodes[0]] = struct{}{}
+		}
+	})
+}
+
+// Check if the table is probably a data table. It's decided by its role,
+// summary, caption, header cells and the number of rows and columns.
+func (r *readability) isDataTable(table *goquery.Selection) bool {
+	if table.AttrOr("role", "") == "presentation" || table.AttrOr("datatable", "") == "0" {
+		return false
+	}
+
+	if table.AttrOr("summary", "") != "" {
+		return true
+	}
+
+	if caption := table.Find("caption").First(); caption.Length() > 0 && caption.Contents().Length() > 0 {
+		return true
+	}
+
+	// Elements that only make sense in data table
+	if table.Find("col,colgroup,tfoot,thead,th").Length() > 0 {
+		return true
+	}
+
+	// Nested tables indicate a layout table
+	if table.Find("table").Length() > 0 {
+		return false
+	}
+
+	rows, columns := 0, 0
+	table.Find("tr").Each(func(_ int, tr *goquery.Selection) {
+		rows++
+
+		rowColumns := 0
+		tr.Find("td").Each(func(_ int, td *goquery.Selection) {
+			colspan, err := strconv.Atoi(td.AttrOr("colspan", "1"))
+			if err != nil || colspan < 1 {
+				colspan = 1
+			}
+			rowColumns += colspan
+		})
+
+		if rowColumns > columns {
+			columns = rowColumns
+		}
+	})
+
+	if rows == 1 || columns == 1 {
+		return false
+	}
+
+	if rows >= 10 || columns > 4 {
+		return true
+	}
+
+	return rows*columns > 10
+}
+
+// Check if the node is a data table or located inside one.
+func (r *readability) hasDataTableAncestor(node *goquery.Selection) bool {
+	for parent := node; len(parent.Nodes) > 0; parent = parent.Parent() {
+		if _, ok := r.dataTables[parent.Nodes[0]]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Clean out spurious headers from an Element. Checks things like classnames and link density.
+func (r *readability) cleanHeaders(s *goquery.Selection) {
+	s.Find("h1,h2,h3").Each(func(_ int, s1 *goquery.Selection) {
+		if r.getClassWeight(s1) < 0 {
+			s1.Remove()
+		}
+	})
+}
+
+// Converts lazy loaded images into the normal one by moving the real URL
+// from data attributes into src and srcset. The images that still using
+// a placeholder afterward will be removed.
+func (r *readability) fixLazyImages(node *goquery.Selection) {
+	if node == nil {
+		return
+	}
+
+	node.Find("img").Each(func(_ int, img *goquery.Selection) {
+		for _, attr := range []string{"data-src", "data-original", "data-lazy-src", "data-url"} {
+			if src := strings.TrimSpace(img.AttrOr(attr, "")); src != "" {
+				img.SetAttr("src", src)
+				img.RemoveAttr(attr)
+				break
+			}
 		}
 
-		if isEmbed && videos.MatchString(target.Text()) {
-			return
+		for _, attr := range []string{"data-srcset", "data-lazy-srcset"} {
+			if srcset := strings.TrimSpace(img.AttrOr(attr, "")); srcset != "" {
+				img.SetAttr("srcset", srcset)
+				img.RemoveAttr(attr)
+				break
+			}
 		}
 
-		target.Remove()
+		if r.isPlaceholderImage(strings.TrimSpace(img.AttrOr("src", ""))) {
+			img.Remove()
+		}
 	})
 }
 
-// Clean an element of all tags of type "tag" if they look fishy.
-// "Fishy" is an algorithm based on content length, classnames, link density, number of images & embeds, etc.
-func (r *readability) cleanConditionally(e *goquery.Selection, tag string) {
-	if e == nil {
+// Converts responsive images into a single image by choosing the highest
+// resolution candidate from srcset, then collapse each <picture> into its <img>.
+func (r *readability) fixResponsiveImages(node *goquery.Selection) {
+	if node == nil {
 		return
 	}
 
-	isList := tag == "ul" || tag == "ol"
-
-	e.Find(tag).Each(func(i int, node *goquery.Selection) {
-		contentScore := 0.0
-		weight := r.getClassWeight(node)
-		if weight+contentScore < 0 {
-			node.Remove()
+	node.Find("picture").Each(func(_ int, picture *goquery.Selection) {
+		img := picture.Find("img").First()
+		if img.Length() == 0 {
+			picture.Remove()
 			return
 		}
 
-		// If there are not very many commas, and the number of
-		// non-paragraph elements is more than paragraphs or other
-		// ominous signs, remove the element.
-		nodeText := normalizeText(node.Text())
-		nCommas := strings.Count(nodeText, ",")
-		nCommas += strings.Count(nodeText, "，")
-		if nCommas < 10 {
-			p := node.Find("p").Length()
-			img := node.Find("img").Length()
-			li := node.Find("li").Length() - 100
-			input := node.Find("input").Length()
+		srcsets := []string{}
+		picture.Find("source").Each(func(_ int, source *goquery.Selection) {
+			srcsets = append(srcsets, source.AttrOr("srcset", ""))
+		})
 
-			embedCount := 0
-			node.Find("embed").Each(func(i int, embed *goquery.Selection) {
-				if !videos.MatchString(embed.AttrOr("src", "")) {
-					embedCount++
-				}
-			})
+		srcsets = append(srcsets, img.AttrOr("srcset", ""))
+		if src := r.getBestImageSource(img.AttrOr("src", ""), srcsets...); src != "" {
+			img.SetAttr("src", src)
+		}
 
-			linkDensity := r.getLinkDensity(node)
-			contentLength := strLen(normalizeText(node.Text()))
-			haveToRemove := (!isList && li > p) ||
-				(img > 1 && float64(p)/float64(img) < 0.5 && !r.hasAncestorTag(node, "figure")) ||
-				(float64(input) > math.Floor(float64(p)/3)) ||
-				(!isList && contentLength < 25 && (img == 0 || img > 2) && !r.hasAncestorTag(node, "figure")) ||
-				(!isList && weight < 25 && linkDensity > 0.2) ||
-				(weight >= 25 && linkDensity > 0.5) ||
-				((embedCount == 1 && contentLength < 75) || embedCount > 1)
+		img.RemoveAttr("srcset")
+		img.RemoveAttr("sizes")
+		picture.ReplaceWithSelection(img)
+	})
 
-			if haveToRemove {
-				node.Remove()
-			}
+	node.Find("img[srcset]").Each(func(_ int, img *goquery.Selection) {
+		if src := r.getBestImageSource(img.AttrOr("src", ""), img.AttrOr("srcset", "")); src != "" {
+			img.SetAttr("src", src)
 		}
+
+		img.RemoveAttr("srcset")
+		img.RemoveAttr("sizes")
 	})
 }
 
-// Clean out spurious headers from an Element. Checks things like classnames and link density.
-func (r *readability) cleanHeaders(s *goquery.Selection) {
-	s.Find("h1,h2,h3").Each(func(_ int, s1 *goquery.Selection) {
-		if r.getClassWeight(s1) < 0 {
-			s1.Remove()
+// Get the highest resolution image from list of srcset. Width descriptors are
+// preferred over pixel density descriptors, since they're more specific.
+// If there are no valid candidate, the default source will be returned.
+func (r *readability) getBestImageSource(defaultSrc string, srcsets ...string) string {
+	bestSrc, bestWidth, bestDensity := "", 0.0, 0.0
+	for _, srcset := range srcsets {
+		for _, candidate := range parseSrcset(srcset) {
+			switch {
+			case candidate.width > bestWidth:
+				bestSrc, bestWidth = candidate.url, candidate.width
+			case candidate.width == 0 && bestWidth == 0:
+				density := candidate.density
+				if density == 0 {
+					density = 1
+				}
+
+				if density > bestDensity {
+					bestSrc, bestDensity = candidate.url, density
+				}
+			}
 		}
-	})
+	}
+
+	if bestSrc == "" {
+		return strings.TrimSpace(defaultSrc)
+	}
+
+	return bestSrc
+}
+
+// Check if the image URL is a placeholder, i.e. a tiny inline image or
+// a spacer image that commonly used for lazy loading.
+func (r *readability) isPlaceholderImage(src string) bool {
+	if strings.HasPrefix(src, "data:image/") && len(src) < 200 {
+		return true
+	}
+
+	return placeholderImages.MatchString(src)
 }
 
 // Converts each <a> and <img> uri in the given element to an absolute URI,
-// ignoring #ref URIs.
+// ignoring #ref URIs since they're pointing to somewhere inside the content.
 func (r *readability) fixRelativeURIs(node *goquery.Selection) {
 	if node == nil {
 		return
@@ -745,59 +2882,131 @@ func (r *readability) fixRelativeURIs(node *goquery.Selection) {
 			img.RemoveAttr("file")
 		}
 
-		if src == "" {
+		if src == "" || !isSafeURL(src, true) {
 			img.Remove()
 			return
 		}
 
-		if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
-			newSrc := nurl.URL(*r.url)
-			if strings.HasPrefix(src, "/") {
-				newSrc.Path = src
-			} else {
-				newSrc.Path = pt.Join(newSrc.Path, src)
+		img.SetAttr("src", r.toAbsoluteURI(src))
+	})
+
+	// Video and audio may have their source in the element itself or in the
+	// <source> and <track> children, plus the poster image
+	node.Find("video,audio,source,track").Each(func(_ int, media *goquery.Selection) {
+		for _, attrName := range []string{"src", "poster"} {
+			value, ok := media.Attr(attrName)
+			if !ok {
+				continue
 			}
-			img.SetAttr("src", newSrc.String())
+
+			if value == "" || !isSafeURL(value, attrName == "poster") {
+				media.RemoveAttr(attrName)
+				continue
+			}
+
+			media.SetAttr(attrName, r.toAbsoluteURI(value))
 		}
 	})
 
 	node.Find("a").Each(func(_ int, link *goquery.Selection) {
-		if href, ok := link.Attr("href"); ok {
-			if !strings.HasPrefix(href, "http://") && !strings.HasPrefix(href, "https://") {
-				newHref := nurl.URL(*r.url)
-				if strings.HasPrefix(href, "/") {
-					newHref.Path = href
-				} else if !strings.HasPrefix(href, "#") {
-					newHref.Path = pt.Join(newHref.Path, href)
-				}
-				link.SetAttr("href", newHref.String())
-			}
+		href, ok := link.Attr("href")
+		if !ok || strings.HasPrefix(href, "#") {
+			return
+		}
+
+		// Link that runs script is replaced by its content, so the text is kept
+		if !isSafeURL(href, false) {
+			link.ReplaceWithSelection(link.Contents())
+			return
 		}
+
+		link.SetAttr("href", r.toAbsoluteURI(href))
 	})
 }
 
+// Converts the URI into an absolute URI by resolving it against the base URL,
+// so its query string, fragment and dot segments are handled properly.
+// If the URI is invalid, it will be returned as it is.
+func (r *readability) toAbsoluteURI(uri string) string {
+	uri = strings.TrimSpace(uri)
+	parsedURI, err := nurl.Parse(uri)
+	if err != nil {
+		return uri
+	}
+
+	return r.resolveReference(parsedURI).String()
+}
+
+// Set the URL that used to resolve relative URLs from <base href>, if the
+// page declares it. It's ignored if it uses scheme that can run script.
+func (r *readability) setBaseURL(doc *goquery.Document) {
+	r.baseURL = nil
+	href := strings.TrimSpace(doc.Find("base[href]").First().AttrOr("href", ""))
+	if href == "" || !isSafeURL(href, false) {
+		return
+	}
+
+	parsedURL, err := nurl.Parse(href)
+	if err != nil {
+		return
+	}
+
+	r.baseURL = r.url.ResolveReference(parsedURL)
+}
+
+// Resolve the URL against the base URL of page, or against the page URL
+// if the page doesn't declare its base URL.
+func (r *readability) resolveReference(ref *nurl.URL) *nurl.URL {
+	if r.baseURL != nil {
+		return r.baseURL.ResolveReference(ref)
+	}
+
+	return r.url.ResolveReference(ref)
+}
+
+// Detect language of the content. For text without word separator like CJK
+// and Thai, the script is more reliable than the detector, which might confuse
+// them with each other.
+func (r *readability) detectLanguage(content *goquery.Selection) wl.Lang {
+	contentText := NormalizeText(removeInvisibleChars(content.Text()))
+	if code := scriptLanguage(contentText); code != "" {
+		return wl.CodeToLang(code)
+	}
+
+	return wl.DetectLang(contentText)
+}
+
+// languageCode returns the language as ISO 639-1 code, or ISO 639-3 code if
+// the language doesn't have the two letters code.
+func languageCode(lang wl.Lang) string {
+	if code := wl.LangToStringShort(lang); code != "" {
+		return code
+	}
+
+	return wl.LangToString(lang)
+}
+
 // Estimate read time based on the language number of character in contents.
 // Using data from http://iovs.arvojournals.org/article.aspx?articleid=2166061
-func (r *readability) estimateReadTime(content *goquery.Selection) (int, int) {
+func (r *readability) estimateReadTime(content *goquery.Selection, lang wl.Lang) (int, int) {
 	if content == nil {
 		return 0, 0
 	}
 
-	// Check the language
-	contentText := normalizeText(content.Text())
-	lang := wl.LangToString(wl.DetectLang(contentText))
+	contentText := NormalizeText(removeInvisibleChars(content.Text()))
 
 	// Get number of words and images
-	nChar := strLen(contentText)
+	nChar := StrLen(contentText)
 	nImg := content.Find("img").Length()
 	if nChar == 0 && nImg == 0 {
 		return 0, 0
 	}
 
 	// Calculate character per minute by language
-	// Fallback to english
+	// Fallback to english, including for languages that not covered by
+	// the study like Korean and Thai
 	var cpm, sd float64
-	switch lang {
+	switch wl.LangToString(lang) {
 	case "arb":
 		sd = 88
 		cpm = 612
@@ -819,6 +3028,9 @@ func (r *readability) estimateReadTime(content *goquery.Selection) (int, int) {
 	case "ita":
 		sd = 140
 		cpm = 950
+	case "cmn":
+		sd = 29
+		cpm = 255
 	case "jpn":
 		sd = 56
 		cpm = 357
@@ -848,9 +3060,23 @@ func (r *readability) estimateReadTime(content *goquery.Selection) (int, int) {
 		cpm = 987
 	}
 
-	// Calculate read time, assumed one image requires 12 second (0.2 minute)
-	minReadTime := float64(nChar)/(cpm+sd) + float64(nImg)*0.2
-	maxReadTime := float64(nChar)/(cpm-sd) + float64(nImg)*0.2
+	// Use the read speed from options if it's specified
+	if r.opts.ReadSpeedCPM > 0 {
+		cpm = r.opts.ReadSpeedCPM
+		sd = 0
+	}
+
+	// Calculate read time, by default one image requires 12 second (0.2 minute)
+	imageReadTime := defaultImageReadTime
+	if r.opts.ImageReadTime < 0 {
+		imageReadTime = 0
+	} else if r.opts.ImageReadTime > 0 {
+		imageReadTime = r.opts.ImageReadTime
+	}
+
+	imageMinutes := float64(nImg) * imageReadTime.Minutes()
+	minReadTime := float64(nChar)/(cpm+sd) + imageMinutes
+	maxReadTime := float64(nChar)/(cpm-sd) + imageMinutes
 
 	// Round number
 	minReadTime = math.Floor(minReadTime + 0.5)
@@ -859,52 +3085,425 @@ func (r *readability) estimateReadTime(content *goquery.Selection) (int, int) {
 	return int(minReadTime), int(maxReadTime)
 }
 
+// Get all images inside the content in document order. Each image URL
+// will only be listed once, using the first alt text found for it.
+func (r *readability) getImages(content *goquery.Selection) []Image {
+	images := []Image{}
+	exist := make(map[string]struct{})
+
+	content.Find("img").Each(func(_ int, img *goquery.Selection) {
+		src := strings.TrimSpace(img.AttrOr("src", ""))
+		if _, ok := exist[src]; ok || src == "" {
+			return
+		}
+
+		exist[src] = struct{}{}
+		images = append(images, Image{
+			URL: src,
+			Alt: NormalizeText(img.AttrOr("alt", "")),
+		})
+	})
+
+	return images
+}
+
+// Insert the image at the beginning of content, unless the content already
+// has it. The image URLs are compared without their scheme and query, since
+// the same image is often served with different size parameters.
+func (r *readability) includeHeroImage(content *goquery.Selection, imageURL string) {
+	if imageURL == "" || !isSafeURL(imageURL, true) {
+		return
+	}
+
+	imageKey := imageURLKey(imageURL)
+	exist := false
+	content.Find("img").EachWithBreak(func(_ int, img *goquery.Selection) bool {
+		exist = imageURLKey(img.AttrOr("src", "")) == imageKey
+		return !exist
+	})
+
+	if exist {
+		return
+	}
+
+	img := &html.Node{
+		Type:     html.ElementNode,
+		Data:     "img",
+		DataAtom: atom.Img,
+		Attr:     []html.Attribute{{Key: "src", Val: imageURL}},
+	}
+
+	parent := content.Get(0)
+	parent.InsertBefore(img, parent.FirstChild)
+}
+
+// imageURLKey returns the image URL without scheme, query and fragment,
+// which is used to check if two URLs point to the same image.
+func imageURLKey(imageURL string) string {
+	parsedURL, err := nurl.Parse(strings.TrimSpace(imageURL))
+	if err != nil {
+		return imageURL
+	}
+
+	return parsedURL.Host + parsedURL.Path
+}
+
+// Get all headings inside the content in document order. The headings
+// without text are skipped.
+func (r *readability) getHeadings(content *goquery.Selection) []Heading {
+	var headings []Heading
+	content.Find("h1,h2,h3,h4,h5,h6").Each(func(_ int, s *goquery.Selection) {
+		text := NormalizeText(s.Text())
+		if text == "" {
+			return
+		}
+
+		level, _ := strconv.Atoi(s.Get(0).Data[1:])
+		headings = append(headings, Heading{
+			Level: level,
+			Text:  text,
+			ID:    strings.TrimSpace(s.AttrOr("id", "")),
+		})
+	})
+
+	return headings
+}
+
 func (r *readability) getHTMLContent(content *goquery.Selection) string {
-	html, err := content.Html()
+	for _, n := range content.Nodes {
+		r.collapseSpaces(n)
+	}
+
+	var html string
+	var err error
+	if r.opts.OuterHTML {
+		html, err = goquery.OuterHtml(content)
+	} else {
+		html, err = content.Html()
+	}
+
 	if err != nil {
 		return ""
 	}
 
-	html = ghtml.UnescapeString(html)
+	// The renderer escapes quotes in text as well, which is not needed
+	// outside attributes, so they're unescaped for readability
+	html = unescapeQuotes(html)
 	html = comments.ReplaceAllString(html, "")
 	html = killBreaks.ReplaceAllString(html, "<br />")
-	html = spaces.ReplaceAllString(html, " ")
 	return html
 }
 
-func (r *readability) getTextContent(content *goquery.Selection) string {
-	var buf bytes.Buffer
+// unescapeQuotes unescapes the quotes in the text of rendered HTML. The other
+// entities like &lt; are kept, so escaped markup in text, e.g. code sample,
+// won't turn into real tags. Quotes inside tags are kept escaped as well, since
+// they're part of attribute values. It relies on the way HTML is rendered,
+// where < and > inside attribute values are always escaped.
+func unescapeQuotes(html string) string {
+	var builder strings.Builder
+	for html != "" {
+		tagStart := strings.IndexByte(html, '<')
+		if tagStart < 0 {
+			tagStart = len(html)
+		}
+
+		tagEnd := strings.IndexByte(html[tagStart:], '>')
+		if tagEnd < 0 {
+			tagEnd = len(html) - tagStart
+		} else {
+			tagEnd += tagStart + 1
+		}
+
+		builder.WriteString(quoteEntities.Replace(html[:tagStart]))
+		builder.WriteString(html[tagStart:tagEnd])
+		html = html[tagEnd:]
+	}
+
+	return builder.String()
+}
+
+// Collapse successive whitespace in text nodes, except inside <pre>
+// where the whitespace is part of the content.
+func (r *readability) collapseSpaces(n *html.Node) {
+	if n.Type == html.ElementNode && n.DataAtom == atom.Pre {
+		return
+	}
+
+	if n.Type == html.TextNode {
+		n.Data = spaces.ReplaceAllString(n.Data, " ")
+		return
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.collapseSpaces(c)
+	}
+}
+
+// Get text of the list with each item prefixed by bullet, or by number for
+// ordered list. Lines after the first one in each item are indented, so
+// nested lists will be indented as well.
+func (r *readability) getListText(list *html.Node) string {
+	items := []string{}
+	for c := list.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.DataAtom != atom.Li {
+			continue
+		}
+
+		marker := "- "
+		if list.DataAtom == atom.Ol {
+			marker = strconv.Itoa(len(items)+1) + ". "
+		}
+
+		text := r.getTextContent(goquery.NewDocumentFromNode(c).Contents())
+		lines := strings.Split(strings.Replace(text, "\n\n", "\n", -1), "\n")
+		for i, line := range lines {
+			if i == 0 {
+				lines[i] = marker + line
+			} else if line != "" {
+				lines[i] = strings.Repeat(" ", len(marker)) + line
+			}
+		}
+
+		items = append(items, strings.Join(lines, "\n"))
+	}
+
+	return strings.Join(items, "\n")
+}
+
+// Get text of the table as pipe-delimited rows, like in Markdown. If the first
+// row is a header row, it will be followed by a separator row.
+func (r *readability) getTableText(table *html.Node) string {
+	rows := []string{}
 
 	var f func(*html.Node)
 	f = func(n *html.Node) {
-		if n.Type == html.TextNode {
-			nodeText := normalizeText(n.Data)
-			if nodeText != "" {
-				buf.WriteString(nodeText)
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || c.DataAtom == atom.Table {
+				continue
 			}
-		} else if n.Parent != nil && n.Parent.DataAtom != atom.P {
-			buf.WriteString("|X|")
-		}
 
-		if n.FirstChild != nil {
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.DataAtom != atom.Tr {
 				f(c)
+				continue
+			}
+
+			cells := []string{}
+			isHeader := true
+			for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.DataAtom != atom.Td && cell.DataAtom != atom.Th {
+					continue
+				}
+
+				cellText := NormalizeText(nodeText(cell))
+				cells = append(cells, strings.Replace(cellText, "|", `\|`, -1))
+				isHeader = isHeader && cell.DataAtom == atom.Th
+			}
+
+			if len(cells) == 0 {
+				continue
+			}
+
+			rows = append(rows, "| "+strings.Join(cells, " | ")+" |")
+			if isHeader && len(rows) == 1 {
+				rows = append(rows, "|"+strings.Repeat(" --- |", len(cells)))
 			}
 		}
 	}
 
-	for _, n := range content.Nodes {
-		f(n)
+	f(table)
+	return strings.Join(rows, "\n")
+}
+
+// textWriter writes the text of content, putting the separator between words
+// and paragraphs as needed, so the text doesn't need to be post-processed.
+type textWriter struct {
+	buffer    bytes.Buffer
+	separator string
+	joinLines bool
+
+	// joinable is true when the last text is paragraph text, so the next
+	// line can be joined into it. inHeading is the depth of headings that
+	// the current text is inside, which are never joined.
+	joinable  bool
+	inHeading int
+}
+
+// Request a separator before the next text. The longer separator wins, so
+// a paragraph break won't be downgraded into a space.
+func (w *textWriter) separate(separator string) {
+	if len(separator) > len(w.separator) {
+		w.separator = separator
+	}
+}
+
+// Write the text, preceded by the requested separator unless it's the
+// beginning of content.
+func (w *textWriter) write(text string) {
+	if text == "" {
+		return
+	}
+
+	if w.buffer.Len() > 0 {
+		w.buffer.WriteString(w.separator)
+	}
+
+	w.separator = ""
+	w.buffer.WriteString(text)
+}
+
+// Write the text of an inline text node. Its whitespace is collapsed, but
+// the leading and trailing ones are kept as separator from the adjacent text.
+func (w *textWriter) writeInline(text string) {
+	if text == "" {
+		return
+	}
+
+	if first, _ := utf8.DecodeRuneInString(text); unicode.IsSpace(first) {
+		w.separate(" ")
+	}
+
+	if w.joinLines && w.inHeading == 0 {
+		text = hyphenatedBreak.ReplaceAllString(text, "$1$2")
+		w.joinLine(NormalizeText(text))
+	}
+
+	w.write(NormalizeText(text))
+	if strings.TrimSpace(text) != "" {
+		w.joinable = w.inHeading == 0
+	}
+
+	if last, _ := utf8.DecodeLastRuneInString(text); unicode.IsSpace(last) {
+		w.separate(" ")
+	}
+}
+
+// Join the text to the previous line if the line break before it is likely
+// a hard wrap, i.e. the previous line doesn't end a sentence and the text
+// starts with lowercase letter. The word that split by hyphen is merged.
+func (w *textWriter) joinLine(text string) {
+	if !w.joinable || (w.separator != "\n" && w.separator != "\n\n") {
+		return
+	}
+
+	if first, _ := utf8.DecodeRuneInString(text); !unicode.IsLower(first) {
+		return
+	}
+
+	prev := w.buffer.Bytes()
+	last, size := utf8.DecodeLastRune(prev)
+	beforeLast, _ := utf8.DecodeLastRune(prev[:len(prev)-size])
+
+	switch {
+	case last == '-' && unicode.IsLetter(beforeLast):
+		w.buffer.Truncate(len(prev) - size)
+		w.separator = ""
+	case unicode.IsLetter(last) || last == ',' || last == ';':
+		w.separator = " "
 	}
+}
+
+// Write the text as its own paragraph.
+func (w *textWriter) writeBlock(text string) {
+	w.separate("\n\n")
+	w.write(text)
+	w.separate("\n\n")
+	w.joinable = false
+}
+
+func (r *readability) getTextContent(content *goquery.Selection) string {
+	w := textWriter{joinLines: r.opts.JoinLines}
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			w.writeInline(n.Data)
+			return
+		case html.ElementNode, html.DocumentNode:
+		default:
+			return
+		}
 
-	finalContent := ""
-	paragraphs := strings.Split(buf.String(), "|X|")
-	for _, paragraph := range paragraphs {
-		if paragraph != "" {
-			finalContent += paragraph + "\n\n"
+		switch n.DataAtom {
+		case atom.Br:
+			w.separate("\n")
+			return
+
+		// Write the alt text of image in bracket, unless it's only a generic
+		// word or file name which is common for decorative image
+		case atom.Img:
+			alt := NormalizeText(attrOr(n, "alt", ""))
+			if r.opts.ImageAltText && !trivialAltText.MatchString(alt) {
+				w.separate(" ")
+				w.write("[" + alt + "]")
+				w.separate(" ")
+			}
+			return
+
+		// Keep the whitespace and line breaks inside <pre> as it is, except the
+		// leading and trailing line breaks
+		case atom.Pre:
+			w.writeBlock(strings.Trim(nodeText(n), "\n"))
+			return
+
+		// Keep cells in the same row on one line, with the caption above them
+		case atom.Table:
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.DataAtom == atom.Caption {
+					w.writeBlock(NormalizeText(nodeText(c)))
+				}
+			}
+
+			w.writeBlock(r.getTableText(n))
+			return
+
+		// Put each list item on its own line with bullet or number
+		case atom.Ul, atom.Ol:
+			w.writeBlock(r.getListText(n))
+			return
+
+		// Mark each line inside blockquote as quotation, like in Markdown
+		case atom.Blockquote:
+			text := r.getTextContent(goquery.NewDocumentFromNode(n).Contents())
+			lines := strings.Split(text, "\n")
+			for i, line := range lines {
+				lines[i] = strings.TrimRight("> "+line, " ")
+			}
+
+			w.writeBlock(strings.Join(lines, "\n"))
+			return
+		}
+
+		// Block element is separated from its surrounding as paragraph,
+		// while inline element is part of the surrounding text
+		isBlock := n.Type == html.ElementNode && !isPhrasingContent(n)
+		if isBlock {
+			w.separate("\n\n")
+		}
+
+		// Heading is never joined with the paragraph around it
+		isHeading := n.DataAtom == atom.H1 || n.DataAtom == atom.H2 || n.DataAtom == atom.H3 ||
+			n.DataAtom == atom.H4 || n.DataAtom == atom.H5 || n.DataAtom == atom.H6
+		if isHeading {
+			w.inHeading++
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
 		}
+
+		if isHeading {
+			w.inHeading--
+		}
+
+		if isBlock {
+			w.separate("\n\n")
+		}
+	}
+
+	for _, n := range content.Nodes {
+		f(n)
 	}
 
-	finalContent = strings.TrimSpace(finalContent)
-	return finalContent
+	return w.buffer.String()
 }
@@ -1,6 +1,23 @@
 package readability
 
 import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/brotli"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	nurl "net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -20,3 +37,2506 @@ func BenchmarkReadability(b *testing.B) {
 		Parse(url, 5*time.Second)
 	}
 }
+
+func BenchmarkTextContent(b *testing.B) {
+	page, err := ioutil.ReadFile("testdata/messy.html")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	html := "<div>" + strings.Repeat(string(page), 200) + "</div>"
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	r := readability{}
+	content := doc.Find("body")
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		r.getTextContent(content)
+	}
+}
+
+func TestParseHTML(t *testing.T) {
+	html := `<html><head><title>Inside Amazon Go, a store of the future</title></head>
+		<body><div class="article"><p>Amazon Go is a new kind of store with no checkout required,
+		which means you never have to wait in line. Just use the Amazon Go app to enter the store,
+		take what you want, and go.</p><p>Our Just Walk Out Shopping experience is made possible by
+		the same types of technologies used in self-driving cars: computer vision, sensor fusion, and
+		deep learning.</p></div></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/news/amazon-go.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if article.Meta.Title != "Inside Amazon Go, a store of the future" {
+		t.Errorf("unexpected title: %q", article.Meta.Title)
+	}
+
+	if !strings.Contains(article.Content, "Amazon Go is a new kind of store") {
+		t.Errorf("unexpected content: %q", article.Content)
+	}
+
+	if article.Meta.Language != "en" {
+		t.Errorf("unexpected language: %q", article.Meta.Language)
+	}
+
+}
+
+func TestPublishedTime(t *testing.T) {
+	html := `<html><head>
+		<meta property="article:published_time" content="2018-01-20T10:00:00Z">
+		<script type="application/ld+json">{"@context": "http://schema.org",
+			"@graph": [{"@type": "NewsArticle", "datePublished": "2018-01-21T08:30:00-05:00"}]}</script>
+		</head><body><time datetime="Sunday morning">Sunday</time></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil && !errors.Is(err, ErrNoContent) {
+		t.Fatal(err)
+	}
+
+	expected := time.Date(2018, 1, 21, 13, 30, 0, 0, time.UTC)
+	if !article.Meta.PublishedTime.Equal(expected) {
+		t.Errorf("expected %v, got %v", expected, article.Meta.PublishedTime)
+	}
+
+	if article.Meta.RawPublishedTime != "2018-01-21T08:30:00-05:00" {
+		t.Errorf("unexpected raw published time: %q", article.Meta.RawPublishedTime)
+	}
+}
+
+func TestJSONLDMetadata(t *testing.T) {
+	html := `<html><head><title>Home | Example</title>
+		<meta name="author" content="https://www.facebook.com/example">
+		<script type="application/ld+json">[{"@type": "WebSite", "name": "Example"},
+			{"@type": ["NewsArticle"], "headline": "Inside Amazon Go, a store of the future",
+			"author": [{"@type": "Person", "name": "Nick Wingfield"}, "Jane Doe"],
+			"image": {"@type": "ImageObject", "url": "https://www.example.com/hero.jpg"},
+			"description": "A store without checkout."}]</script>
+		</head><body></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil && !errors.Is(err, ErrNoContent) {
+		t.Fatal(err)
+	}
+
+	meta := article.Meta
+	if meta.Title != "Inside Amazon Go, a store of the future" {
+		t.Errorf("unexpected title: %q", meta.Title)
+	}
+
+	if meta.Author != "Nick Wingfield, Jane Doe" || len(meta.Authors) != 2 {
+		t.Errorf("unexpected author: %q %q", meta.Author, meta.Authors)
+	}
+
+	if meta.Image != "https://www.example.com/hero.jpg" {
+		t.Errorf("unexpected image: %q", meta.Image)
+	}
+
+	if meta.Excerpt != "A store without checkout." {
+		t.Errorf("unexpected excerpt: %q", meta.Excerpt)
+	}
+}
+
+func TestSiteName(t *testing.T) {
+	tests := map[string]string{
+		`<meta property="og:site_name" content="The New York Times">
+			<script type="application/ld+json">{"@type": "NewsArticle", "publisher": {"@type": "Organization", "name": "NYT"}}</script>`: "The New York Times",
+		`<script type="application/ld+json">{"@type": "NewsArticle", "publisher": {"@type": "Organization", "name": "NYT"}}</script>`: "NYT",
+		``: "example.co.uk",
+	}
+
+	for head, siteName := range tests {
+		html := "<html><head>" + head + "</head><body></body></html>"
+		article, err := ParseHTML(html, "https://www.example.co.uk/")
+		if err != nil && !errors.Is(err, ErrNoContent) {
+			t.Fatal(err)
+		}
+
+		if article.Meta.SiteName != siteName {
+			t.Errorf("%.40q: unexpected site name: %q", head, article.Meta.SiteName)
+		}
+	}
+}
+
+func TestCanonicalURL(t *testing.T) {
+	tests := map[string]string{
+		`<link rel="canonical" href="https://www.example.com/news/amazon-go">
+			<meta property="og:url" content="https://www.example.com/">`: "https://www.example.com/news/amazon-go",
+		`<meta property="og:url" content="https://www.example.com/news/amazon-go">`: "https://www.example.com/news/amazon-go",
+		`<link rel="canonical" href="amazon-go">`:                                   "https://www.example.com/news/amazon-go",
+		``: "",
+	}
+
+	for head, canonicalURL := range tests {
+		html := "<html><head>" + head + "</head><body></body></html>"
+		article, err := ParseHTML(html, "https://www.example.com/news/amazon-go?utm_source=feed")
+		if err != nil && !errors.Is(err, ErrNoContent) {
+			t.Fatal(err)
+		}
+
+		if article.Meta.CanonicalURL != canonicalURL {
+			t.Errorf("%.40q: unexpected canonical URL: %q", head, article.Meta.CanonicalURL)
+		}
+	}
+}
+
+func TestAuthors(t *testing.T) {
+	html := `<html><head>
+		<meta property="article:author" content="Nick Wingfield">
+		<meta property="article:author" content="Jane Doe">
+		<meta name="author" content="Nick Wingfield and John Smith, Jr.">
+		</head><body></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil && !errors.Is(err, ErrNoContent) {
+		t.Fatal(err)
+	}
+
+	meta := article.Meta
+	if !reflect.DeepEqual(meta.Authors, []string{"Nick Wingfield", "Jane Doe", "John Smith, Jr."}) {
+		t.Errorf("unexpected authors: %q", meta.Authors)
+	}
+
+	if meta.Author != "Nick Wingfield, Jane Doe, John Smith, Jr." {
+		t.Errorf("unexpected author: %q", meta.Author)
+	}
+}
+
+func TestInlineByline(t *testing.T) {
+	bylines := []string{
+		`<div class="byline">By <a href="/authors/nick" rel="author">Nick Wingfield</a>, January 21</div>`,
+		`<div class="byline">by: Nick Wingfield</div>`,
+	}
+
+	for _, byline := range bylines {
+		html := `<html><body><article>` + byline + `
+			<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+			<p>Just use the Amazon Go app to enter the store, take the products you want, and go.</p>
+			</article></body></html>`
+
+		article, err := ParseHTML(html, "https://www.example.com/amazon-go")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if article.Meta.Author != "Nick Wingfield" || len(article.Meta.Authors) != 1 {
+			t.Errorf("unexpected author: %q %q", article.Meta.Author, article.Meta.Authors)
+		}
+
+		if strings.Contains(article.Content, "Nick Wingfield") {
+			t.Errorf("byline is not removed from content: %q", article.Content)
+		}
+	}
+}
+
+func TestMetaDescription(t *testing.T) {
+	html := `<html><head><meta name="description" content="Best store, cheap store, Amazon store">
+		<meta property="og:description" content="A store without checkout."></head>
+		<body><p>Amazon Go is a new kind of store with no checkout required.</p></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if article.Meta.MetaDescription != "Best store, cheap store, Amazon store" {
+		t.Errorf("unexpected meta description: %q", article.Meta.MetaDescription)
+	}
+
+	if article.Meta.Excerpt != article.Meta.MetaDescription {
+		t.Errorf("unexpected excerpt: %q", article.Meta.Excerpt)
+	}
+}
+
+func TestReplaceBrs(t *testing.T) {
+	tests := map[string]string{
+		`<div>foo<br>bar<br> <br><br>abc</div>`:            `<div>foo<br/>bar<p> abc</p></div>`,
+		`<div>foo<br><br>bar <b>baz</b><div>x</div></div>`: `<div>foo<p>bar <b>baz</b></p><div>x</div></div>`,
+		`<p>foo<br><br>bar<br><br>baz</p>`:                 `<div>foo<p>bar</p><p>baz</p></div>`,
+		`<div><span>foo<br><br>bar</span><br></div>`:       `<div><span>foo<p>bar</p></span><br/></div>`,
+	}
+
+	for input, expected := range tests {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := readability{}
+		r.replaceBrs(doc)
+		if result, _ := doc.Find("body").Html(); result != expected {
+			t.Errorf("replaceBrs(%q) = %q, want %q", input, result, expected)
+		}
+	}
+}
+
+func TestFavicon(t *testing.T) {
+	tests := map[string]string{
+		`<link rel="stylesheet" href="/style.css">`:             "https://www.example.com/favicon.ico",
+		`<link rel="shortcut icon" href="/static/favicon.ico">`: "https://www.example.com/static/favicon.ico",
+		`<link rel="icon" href="icon-16.png" sizes="16x16">
+			<link rel="apple-touch-icon" href="//cdn.example.com/touch.png" sizes="120x120 180x180">
+			<link rel="icon" href="icon-32.png" sizes="32x32">`: "https://cdn.example.com/touch.png",
+	}
+
+	for head, expected := range tests {
+		html := "<html><head>" + head + "</head><body></body></html>"
+		article, err := ParseHTML(html, "https://www.example.com/news/amazon-go.html")
+		if err != nil && !errors.Is(err, ErrNoContent) {
+			t.Fatal(err)
+		}
+
+		if article.Meta.Favicon != expected {
+			t.Errorf("expected favicon %q, got %q", expected, article.Meta.Favicon)
+		}
+	}
+}
+
+func TestPrepareDocumentLinks(t *testing.T) {
+	html := `<html><head><link rel="stylesheet" href="/style.css"><link rel="Alternate StyleSheet" href="/dark.css">
+		<link rel="canonical" href="/amazon-go"><link rel="icon" href="/icon.png"></head><body></body></html>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := readability{}
+	r.prepareDocument(doc)
+
+	if n := doc.Find(`link[href$=".css"]`).Length(); n != 0 {
+		t.Errorf("%d stylesheets are not removed", n)
+	}
+
+	if n := doc.Find(`link[rel="canonical"], link[rel="icon"]`).Length(); n != 2 {
+		t.Errorf("expected metadata links are kept, got %d", n)
+	}
+}
+
+func TestTags(t *testing.T) {
+	html := `<html><head><meta property="article:tag" content="Amazon">
+		<meta property="article:tag" content=" Retail "><meta name="keywords" content="amazon, grocery,,Seattle">
+		</head><body><p>Filed under <a href="/tag/technology" rel="tag">Technology</a> and
+		<a href="/tag/seattle" rel="category tag">Seattle</a>.</p></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil && !errors.Is(err, ErrNoContent) {
+		t.Fatal(err)
+	}
+
+	expected := []string{"Amazon", "Retail", "grocery", "Seattle", "Technology"}
+	if strings.Join(article.Meta.Tags, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected tags %q, got %q", expected, article.Meta.Tags)
+	}
+}
+
+func TestInvisibleChars(t *testing.T) {
+	html := "<html><body><div class=\"post\"><p>\uFEFFAmazon Go is a new kind of store with no check\u00ADout " +
+		"re\u200Bquired, which means you never have to wait in line.</p></div></body></html>"
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line."
+	if article.Content != expected || article.Meta.Excerpt != expected {
+		t.Errorf("invisible characters are not removed: %q", article.Content)
+	}
+
+	if !strings.Contains(article.RawContent, "check\u00ADout") {
+		t.Errorf("soft hyphen is removed from HTML: %q", article.RawContent)
+	}
+
+	article, err = New(Options{KeepInvisibleChars: true}).ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(article.Content, "check\u00ADout") {
+		t.Errorf("invisible characters are not kept: %q", article.Content)
+	}
+}
+
+func TestImageAltText(t *testing.T) {
+	html := `<html><body><article>
+		<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<figure><img src="/store.jpg" alt="The  store in Seattle"><img src="/line.gif" alt="image"><img src="/app.png" alt="IMG_1234.png"></figure>
+		<p>Just use the app <img src="/app-icon.png" alt="Amazon Go app">to enter the store, take the products you want, and go.</p>
+		</article></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil || strings.Contains(article.Content, "[") {
+		t.Fatalf("alt text is written by default: %q (%v)", article.Content, err)
+	}
+
+	article, err = New(Options{ImageAltText: true}).ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.\n\n" +
+		"[The store in Seattle]\n\n" +
+		"Just use the app [Amazon Go app] to enter the store, take the products you want, and go."
+	if article.Content != expected {
+		t.Errorf("unexpected content: %q", article.Content)
+	}
+}
+
+func TestContentElement(t *testing.T) {
+	html := `<html><body><nav><a href="/">Home</a></nav>
+		<section lang="en"><h2>The store</h2>
+		<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<p>Just use the Amazon Go app to enter the store, take the products you want, and go, without any cashier.</p>
+		</section></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := strings.TrimSpace(article.RawContent)
+	if !strings.HasPrefix(content, `<section lang="en">`) || !strings.HasSuffix(content, "</section>") {
+		t.Errorf("selected element is not included: %q", article.RawContent)
+	}
+
+	article, err = New(Options{OuterHTML: true}).ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content = strings.TrimSpace(article.RawContent)
+	if !strings.HasPrefix(content, `<div><section lang="en">`) || !strings.HasSuffix(content, "</section></div>") {
+		t.Errorf("content is not wrapped: %q", article.RawContent)
+	}
+}
+
+func TestDetails(t *testing.T) {
+	html := `<html><body><div class="faq">
+		<details><summary>How do I enter the store?</summary>Just use the Amazon Go app to enter the store, scan the code at the gate, and walk in.</details>
+		<details><summary>How do I pay?</summary>You don't have to wait in line, since the products you take are <b>charged</b> to your account when you leave.</details>
+		<details open><summary>Is there a cashier?</summary><p>No, there is no cashier, and the store uses cameras and weight sensors to track the products, which are put back again.</p></details>
+		</div></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "How do I enter the store?\n\n" +
+		"Just use the Amazon Go app to enter the store, scan the code at the gate, and walk in.\n\n" +
+		"How do I pay?\n\n" +
+		"You don't have to wait in line, since the products you take are charged to your account when you leave.\n\n" +
+		"Is there a cashier?\n\n" +
+		"No, there is no cashier, and the store uses cameras and weight sensors to track the products, which are put back again."
+	if article.Content != expected {
+		t.Errorf("unexpected content: %q", article.Content)
+	}
+
+	if strings.Count(article.RawContent, "<details open=\"\">") != 3 {
+		t.Errorf("details are not opened: %q", article.RawContent)
+	}
+
+	if !strings.Contains(article.Markdown, "**How do I pay?**\n\nYou don't have to wait in line") {
+		t.Errorf("summary is not written as bold paragraph: %q", article.Markdown)
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	html := `<html><body><article>
+		<p>Amazon Go is a new kind of <em>store</em> with no checkout required,<br>so you never have to wait in line.</p>
+		<h2>How it works</h2>
+		<figure><img src="/store.jpg" alt="The store"><figcaption>The first store in Seattle.</figcaption></figure>
+		<ol><li>Open the <a href="/app">app</a>.</li><li>Take the products.</li></ol>
+		<blockquote><p>It's like magic.</p><p>Really.</p></blockquote>
+		<pre>go run main.go
+  --store seattle</pre>
+		<div>Just use the Amazon Go app to enter the store, take the products you want, and go, without any cashier.</div>
+		</article></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Block{
+		{Type: BlockParagraph, Text: "Amazon Go is a new kind of store with no checkout required, so you never have to wait in line."},
+		{Type: BlockHeading, Text: "How it works", Level: 2},
+		{Type: BlockImage, URL: "https://www.example.com/store.jpg", Alt: "The store"},
+		{Type: BlockParagraph, Text: "The first store in Seattle."},
+		{Type: BlockList, Items: []string{"Open the app.", "Take the products."}, Ordered: true},
+		{Type: BlockQuote, Text: "It's like magic.\n\nReally."},
+		{Type: BlockCode, Text: "go run main.go\n  --store seattle"},
+		{Type: BlockParagraph, Text: "Just use the Amazon Go app to enter the store, take the products you want, and go, without any cashier."},
+	}
+
+	if !reflect.DeepEqual(article.Blocks, expected) {
+		t.Errorf("unexpected blocks:\n%+v", article.Blocks)
+	}
+}
+
+func TestHeadings(t *testing.T) {
+	html := `<html><head><title>Inside Amazon Go</title></head><body><article>
+		<h1>Inside Amazon Go</h1>
+		<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<h2 id="how-it-works" class="section-title">How it <em>works</em></h2>
+		<p>Just use the Amazon Go app to enter the store, take the products you want, and go, without any cashier.</p>
+		<h3 id="sensors">Sensors</h3>
+		<p>Cameras and weight sensors on the shelves track which products are taken, and which are put back again.</p>
+		<h3>Privacy</h3>
+		<p>Amazon says the cameras don't use facial recognition, and the footage is only used to track the products.</p>
+		</article></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Heading{
+		{Level: 2, Text: "How it works", ID: "how-it-works"},
+		{Level: 3, Text: "Sensors", ID: "sensors"},
+		{Level: 3, Text: "Privacy"},
+	}
+
+	if !reflect.DeepEqual(article.Headings, expected) {
+		t.Errorf("unexpected headings: %+v", article.Headings)
+	}
+
+	if !strings.Contains(article.RawContent, `<h2 id="how-it-works">`) {
+		t.Errorf("heading id is not kept: %q", article.RawContent)
+	}
+
+	// A single heading that repeats the title is removed
+	html = strings.Replace(html, "<h1>Inside Amazon Go</h1>", "<h3>Inside Amazon Go!</h3>", 1)
+	html = strings.Replace(html, "<h3>Privacy</h3>", "", 1)
+	html = strings.Replace(html, `<h3 id="sensors">Sensors</h3>`, "", 1)
+	article, err = ParseHTML(html, "https://www.example.com/")
+	if err != nil || len(article.Headings) != 1 || article.Headings[0].Text != "How it works" {
+		t.Errorf("unexpected headings: %+v (%v)", article.Headings, err)
+	}
+}
+
+func TestPreformattedContent(t *testing.T) {
+	html := `<div><p>Print   it with:</p><pre><code>if ok {
+    fmt.Println("ok")	
+
+
+}
+</code></pre></div>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := readability{}
+	content := doc.Find("div").First()
+	code := "if ok {\n    fmt.Println(\"ok\")\t\n\n\n}"
+
+	text := r.getTextContent(content)
+	if text != "Print it with:\n\n"+code {
+		t.Errorf("unexpected text content: %q", text)
+	}
+
+	rawHTML := r.getHTMLContent(content)
+	if rawHTML != "<p>Print it with:</p><pre><code>"+code+"\n</code></pre>" {
+		t.Errorf("unexpected HTML content: %q", rawHTML)
+	}
+}
+
+func TestLazyImages(t *testing.T) {
+	html := `<html><body><div class="post"><p>Amazon Go is a new kind of store with no checkout
+		required, which means you never have to wait in line.</p>
+		<p><img src="data:image/gif;base64,R0lGODlhAQABAAAAACH5BAEKAAEALAAAAAABAAEAAAICTAEAOw=="
+			data-src="/images/store.jpg" alt="Store"></p>
+		<p><img src="/static/spacer.gif" alt="Nothing"></p></div></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/news/amazon-go.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(article.RawContent, `src="https://www.example.com/images/store.jpg"`) {
+		t.Errorf("lazy image is not promoted: %q", article.RawContent)
+	}
+
+	if strings.Contains(article.RawContent, "spacer.gif") {
+		t.Errorf("placeholder image is not removed: %q", article.RawContent)
+	}
+}
+
+func TestNoscriptImages(t *testing.T) {
+	html := `<html><body><div class="post"><p>Amazon Go is a new kind of store with no checkout
+		required, which means you never have to wait in line.</p>
+		<figure><span class="lazy-wrapper"><img src="/static/spacer.gif" alt="Store"></span>
+		<noscript><img src="/images/store.jpg" alt="Store"></noscript></figure>
+		<p>Just use the Amazon Go app to enter the store, take the products you want, and go.</p>
+		</div></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/news/amazon-go.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Image{URL: "https://www.example.com/images/store.jpg", Alt: "Store"}
+	if len(article.Images) != 1 || article.Images[0] != expected {
+		t.Errorf("noscript image is not recovered: %+v", article.Images)
+	}
+}
+
+func TestHeroImage(t *testing.T) {
+	html := `<html><body><div class="post">
+		<p><img src="/icons/share.png" width="16" height="16"><img src="/images/thumb.jpg" width="200" height="150"></p>
+		<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<figure><img src="/images/store.jpg" width="1200" height="800"><figcaption>The store</figcaption></figure>
+		</div></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/news/amazon-go.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if article.Meta.Image != "https://www.example.com/images/store.jpg" {
+		t.Errorf("unexpected hero image: %q", article.Meta.Image)
+	}
+
+	html = strings.Replace(html, "<html>", `<html><head><meta property="og:image" content="/og.jpg"></head>`, 1)
+	article, err = ParseHTML(html, "https://www.example.com/news/amazon-go.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if article.Meta.Image != "https://www.example.com/og.jpg" {
+		t.Errorf("declared image is not preferred: %q", article.Meta.Image)
+	}
+}
+
+func TestIncludeHeroImage(t *testing.T) {
+	paragraphs := `<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<p>Just use the Amazon Go app to enter the store, take the products you want, and go, without any cashier.</p>`
+
+	html := `<html><head><meta property="og:image" content="https://cdn.example.com/store.jpg?w=1200"></head>
+		<body><article>` + paragraphs + `</article></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil || len(article.Images) != 0 {
+		t.Fatalf("image is included by default: %+v (%v)", article.Images, err)
+	}
+
+	article, err = New(Options{IncludeHeroImage: true}).ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(article.Images) != 1 || article.Images[0].URL != "https://cdn.example.com/store.jpg?w=1200" {
+		t.Errorf("hero image is not included: %+v", article.Images)
+	}
+
+	if !strings.HasPrefix(strings.TrimSpace(article.RawContent), `<img src="https://cdn.example.com/store.jpg?w=1200"`) {
+		t.Errorf("hero image is not on top of content: %q", article.RawContent)
+	}
+
+	// The image which already in content is not included twice
+	html = strings.Replace(html, "<article>", `<article><img src="//cdn.example.com/store.jpg?w=600">`, 1)
+	article, err = New(Options{IncludeHeroImage: true}).ParseHTML(html, "https://www.example.com/")
+	if err != nil || len(article.Images) != 1 || article.Images[0].URL != "https://cdn.example.com/store.jpg?w=600" {
+		t.Errorf("hero image is included twice: %+v (%v)", article.Images, err)
+	}
+}
+
+func TestResponsiveImages(t *testing.T) {
+	html := `<html><body><div class="post"><p>Amazon Go is a new kind of store with no checkout
+		required, which means you never have to wait in line.</p>
+		<picture>
+			<source srcset="/img/store-800.webp 800w, /img/store-1600.webp 1600w" type="image/webp">
+			<img src="/img/store.jpg" srcset="/img/store-1200.jpg 1200w" alt="Store">
+		</picture>
+		<p><img src="/img/map.png" srcset="/img/map.png 1x, /img/map@2x.png 2x" alt="Map"></p>
+		<p><img src="/upload/w_300,h_200/app.jpg" srcset="/upload/w_300,h_200/app.jpg 300w,/upload/w_600,h_400/app.jpg 600w" alt="App"></p>
+		</div></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/news/amazon-go.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(article.RawContent, "<picture") || strings.Contains(article.RawContent, "srcset") {
+		t.Errorf("responsive images are not collapsed: %q", article.RawContent)
+	}
+
+	for _, src := range []string{"/img/store-1600.webp", "/img/map@2x.png", "/upload/w_600,h_400/app.jpg"} {
+		if !strings.Contains(article.RawContent, `src="https://www.example.com`+src+`"`) {
+			t.Errorf("%s is not chosen: %q", src, article.RawContent)
+		}
+	}
+}
+
+func TestImages(t *testing.T) {
+	html := `<html><body><div class="post"><p>Amazon Go is a new kind of store with no checkout
+		required, which means you never have to wait in line.</p>
+		<p><img src="/img/store.jpg" alt="  The   store "><img src="/img/map.png"></p>
+		<p>Just use the Amazon Go app to enter the store, take the products you want, and go.</p>
+		<p><img src="/img/store.jpg" alt="Store again"><img src="https://cdn.example.com/app.png" alt="App"></p>
+		</div></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/news/amazon-go.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Image{
+		{URL: "https://www.example.com/img/store.jpg", Alt: "The store"},
+		{URL: "https://www.example.com/img/map.png", Alt: ""},
+		{URL: "https://cdn.example.com/app.png", Alt: "App"},
+	}
+
+	if !reflect.DeepEqual(article.Images, expected) {
+		t.Errorf("expected %v, got %v", expected, article.Images)
+	}
+}
+
+func TestFixRelativeURIs(t *testing.T) {
+	html := `<div>
+		<a href="page?id=5#section">query</a>
+		<a href="../archive/2018/">parent</a>
+		<a href="/about">root</a>
+		<a href="#top">fragment</a>
+		<a href="https://www.other.com/x">absolute</a>
+		<a href="//cdn.example.com/file.pdf">protocol-relative</a>
+		<img src="img/photo.jpg?w=300">
+	</div>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pageURL, _ := nurl.Parse("https://www.example.com/news/2018/story.html")
+	r := readability{url: pageURL}
+	content := doc.Find("div").First()
+	r.fixRelativeURIs(content)
+
+	expected := []string{
+		"https://www.example.com/news/2018/page?id=5#section",
+		"https://www.example.com/news/archive/2018/",
+		"https://www.example.com/about",
+		"#top",
+		"https://www.other.com/x",
+		"https://cdn.example.com/file.pdf",
+	}
+
+	content.Find("a").Each(func(i int, link *goquery.Selection) {
+		if href := link.AttrOr("href", ""); href != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], href)
+		}
+	})
+
+	if src := content.Find("img").AttrOr("src", ""); src != "https://www.example.com/news/2018/img/photo.jpg?w=300" {
+		t.Errorf("unexpected image source: %q", src)
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	html := `<html><head><base href="/static/"><meta property="og:image" content="cover.jpg"></head><body><article>
+		<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<p>Just use the <a href="app/download">Amazon Go app</a> to enter the store, take the products you want, and go.<img src="store.jpg"></p>
+		<p>Read <a href="#faq">the FAQ</a> or <a href="/about">about us</a> for more details about the store and the app.</p>
+		</article></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/news/2018/story.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		`href="https://www.example.com/static/app/download"`,
+		`src="https://www.example.com/static/store.jpg"`,
+		`href="#faq"`,
+		`href="https://www.example.com/about"`,
+	} {
+		if !strings.Contains(article.RawContent, expected) {
+			t.Errorf("URL is not resolved against base, expected %s: %q", expected, article.RawContent)
+		}
+	}
+
+	if article.Meta.Image != "https://www.example.com/static/cover.jpg" {
+		t.Errorf("unexpected image: %q", article.Meta.Image)
+	}
+
+	if article.URL != "https://www.example.com/news/2018/story.html" {
+		t.Errorf("unexpected URL: %q", article.URL)
+	}
+
+	// Base URL that runs script is ignored
+	html = strings.Replace(html, `<base href="/static/">`, `<base href="javascript:alert(1)//">`, 1)
+	article, err = ParseHTML(html, "https://www.example.com/news/2018/story.html")
+	if err != nil || !strings.Contains(article.RawContent, `src="https://www.example.com/news/2018/store.jpg"`) {
+		t.Errorf("unsafe base URL is used: %q (%v)", article.RawContent, err)
+	}
+}
+
+func TestEscapedAttributes(t *testing.T) {
+	html := `<html><body><article>
+		<p>Amazon Go is a new kind of <a href="/search?q=store&amp;sort=new">store</a> with no checkout required.</p>
+		<p>Just use the app to enter the store, take the products you want, and go.<img src="/img?caption=&quot;store&quot;&amp;w=600"></p>
+		</article></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		`href="https://www.example.com/search?q=store&amp;sort=new"`,
+		`src="https://www.example.com/img?caption=&#34;store&#34;&amp;w=600"`,
+	} {
+		if !strings.Contains(article.RawContent, expected) {
+			t.Errorf("attribute is not escaped correctly, expected %s: %q", expected, article.RawContent)
+		}
+	}
+
+	if len(article.Images) != 1 || article.Images[0].URL != `https://www.example.com/img?caption="store"&w=600` {
+		t.Errorf("unexpected images: %+v", article.Images)
+	}
+}
+
+func TestEscapedMarkup(t *testing.T) {
+	html := `<html><body><article>
+		<p>To load the library, add &lt;script src="app.js"&gt;&lt;/script&gt; to the page, and you're done.</p>
+		<p>Never put user input in the page as it is, because &lt;script&gt;alert(1)&lt;/script&gt; will be run.</p>
+		<pre><code>if a &lt; b &amp;&amp; b &gt; c {}</code></pre>
+		</article></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		`add &lt;script src="app.js"&gt;&lt;/script&gt; to the page, and you're done.`,
+		`&lt;script&gt;alert(1)&lt;/script&gt; will be run.`,
+		`if a &lt; b &amp;&amp; b &gt; c {}`,
+	} {
+		if !strings.Contains(article.RawContent, expected) {
+			t.Errorf("escaped markup is changed, expected %s: %q", expected, article.RawContent)
+		}
+	}
+
+	if strings.Contains(article.RawContent, "<script") {
+		t.Errorf("escaped markup becomes real tag: %q", article.RawContent)
+	}
+
+	if !strings.Contains(article.Content, `add <script src="app.js"></script> to the page`) {
+		t.Errorf("unexpected text content: %q", article.Content)
+	}
+}
+
+func TestUnsafeURLs(t *testing.T) {
+	html := `<html><body><div class="post"><p>Amazon Go is a new kind of store with no checkout
+		required, which means you <a href="javascript:void(0)">never</a> have to wait in line.</p>
+		<p><img src="data:text/html;base64,PHNjcmlwdD4=" alt="Fake"><img src="/images/store.jpg" alt="Store"></p>
+		</div></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/news/amazon-go.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(article.RawContent, "javascript") || strings.Contains(article.RawContent, "data:") {
+		t.Errorf("unsafe URL is not removed: %q", article.RawContent)
+	}
+
+	if !strings.Contains(article.RawContent, "you never have") {
+		t.Errorf("text of unsafe link is removed: %q", article.RawContent)
+	}
+
+	if len(article.Images) != 1 || article.Images[0].Alt != "Store" {
+		t.Errorf("unexpected images: %+v", article.Images)
+	}
+}
+
+func TestPreserveAttributes(t *testing.T) {
+	html := `<html><body><div class="post"><p>Amazon Go is a new kind of store with no checkout required,
+		which means you never have to wait in line.</p>
+		<pre class="language-go" style="color: red">fmt.Println("Amazon Go")</pre>
+		</div></body></html>`
+
+	for _, sanitize := range []bool{false, true} {
+		opts := Options{PreserveAttributes: []string{"class"}, Sanitize: sanitize}
+		article, err := New(opts).ParseHTML(html, "https://www.example.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(article.RawContent, `<pre class="language-go">`) {
+			t.Errorf("unexpected attributes with sanitize %v: %q", sanitize, article.RawContent)
+		}
+	}
+}
+
+func TestMediaElements(t *testing.T) {
+	html := `<html><body><div class="post">
+		<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<div><video controls poster="/images/poster.jpg"><source src="/videos/store.webm" type="video/webm">
+			<source src="/videos/store.mp4" type="video/mp4"><track src="/videos/store.vtt" kind="captions"></video></div>
+		<p>Listen to the interview with the store manager, who talks about the technology behind it.</p>
+		<div><audio src="podcast/episode-1.mp3" controls></audio></div>
+		</div></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/news/amazon-go.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		`poster="https://www.example.com/images/poster.jpg"`,
+		`<source src="https://www.example.com/videos/store.webm" type="video/webm"/>`,
+		`<source src="https://www.example.com/videos/store.mp4" type="video/mp4"/>`,
+		`<track src="https://www.example.com/videos/store.vtt" kind="captions"/>`,
+		`<audio src="https://www.example.com/news/podcast/episode-1.mp3" controls=""></audio>`,
+	} {
+		if !strings.Contains(article.RawContent, expected) {
+			t.Errorf("%s is missing: %q", expected, article.RawContent)
+		}
+	}
+}
+
+func TestAllowedEmbeds(t *testing.T) {
+	html := `<html><body><div class="post">
+		<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<iframe src="https://fast.wistia.net/embed/iframe/abc123"></iframe>
+		<iframe src="https://video.example.com/player/42"></iframe>
+		<p>Just use the Amazon Go app to enter the store, take the products you want, and go.</p>
+		</div></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/news/amazon-go.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(article.RawContent, "wistia.net") || strings.Contains(article.RawContent, "video.example.com") {
+		t.Errorf("unexpected embeds with default pattern: %q", article.RawContent)
+	}
+
+	opts := Options{AllowedEmbeds: regexp.MustCompile(`//video\.example\.com/player/`)}
+	article, err = New(opts).ParseHTML(html, "https://www.example.com/news/amazon-go.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(article.RawContent, "wistia.net") || !strings.Contains(article.RawContent, "video.example.com") {
+		t.Errorf("unexpected embeds with custom pattern: %q", article.RawContent)
+	}
+}
+
+func TestFootnoteLinks(t *testing.T) {
+	html := `<html><body><div id="content">
+		<p>Amazon Go is a new kind of store with no checkout required<sup><a href="#cite-1">[1]</a></sup>,
+		which means you never have to wait in line<sup><a href="#cite-2">[2]</a></sup>.</p>
+		<h2>References</h2>
+		<ol id="references">
+			<li id="cite-1">Wingfield, Nick. "Inside Amazon Go, a Store of the Future".</li>
+			<li id="cite-2">Amazon. "Amazon Go".</li>
+		</ol></div></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/wiki/Amazon_Go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"cite-1", "cite-2"} {
+		if !strings.Contains(article.RawContent, `href="#`+id+`"`) {
+			t.Errorf("link to %s is changed: %q", id, article.RawContent)
+		}
+
+		if !strings.Contains(article.RawContent, `id="`+id+`"`) {
+			t.Errorf("id %s is removed: %q", id, article.RawContent)
+		}
+	}
+
+	if strings.Contains(article.RawContent, `id="references"`) {
+		t.Errorf("id which not targeted by any link is kept: %q", article.RawContent)
+	}
+}
+
+func TestHiddenWidgets(t *testing.T) {
+	html := `<html><body><div id="content">
+		<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<div data-widget="share-bar"><p>Share this article on Facebook, Twitter and everywhere else you like.</p></div>
+		<div role="complementary"><p>You might also like these stories that we picked specially for you.</p></div>
+		<p aria-hidden="true">Subscribe to our newsletter to get the latest news right in your inbox.</p>
+		<p hidden>This paragraph is hidden from the reader and shouldn't be extracted at all.</p>
+		<p style="display: none">Hidden SEO text which stuffed with keywords about the store and so on.</p>
+		<p>Just use the Amazon Go app to enter the store, take the products you want, and go.</p>
+		</div></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/amazon-go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(article.Content, "Amazon Go app") {
+		t.Errorf("content is missing: %q", article.Content)
+	}
+
+	for _, text := range []string{"Share this", "might also like", "Subscribe", "hidden from", "SEO text"} {
+		if strings.Contains(article.Content, text) {
+			t.Errorf("widget %q is not removed: %q", text, article.Content)
+		}
+	}
+}
+
+func TestLinkDensity(t *testing.T) {
+	html := `<div>
+		<p id="inline">Amazon Go is a store <a href="#note-1">with no checkout</a> required.</p>
+		<p id="external">Amazon Go is a store <a href="/go">with no checkout</a> required.</p>
+		</div>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := readability{}
+	inline := r.getLinkDensity(doc.Find("#inline"))
+	external := r.getLinkDensity(doc.Find("#external"))
+	if inline >= external {
+		t.Errorf("fragment link is not discounted: %f >= %f", inline, external)
+	}
+}
+
+func TestCommentWidgets(t *testing.T) {
+	comment := `<p>I went there last week and honestly, the whole thing felt a bit creepy, with cameras everywhere.</p>`
+	html := `<html><body>
+		<div class="story"><p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p></div>
+		<div class="coral-talk-stream"><div>` + strings.Repeat(comment, 5) + `</div></div>
+		<div class="story-discussion"><iframe src="https://example.disqus.com/embed/comments/?t=amazon-go"></iframe></div>
+		</body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/amazon-go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if article.Content != "Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line." {
+		t.Errorf("unexpected content: %q", article.Content)
+	}
+
+	if strings.Contains(article.RawContent, "disqus") {
+		t.Errorf("comment iframe is not removed: %q", article.RawContent)
+	}
+}
+
+func TestRelatedLinks(t *testing.T) {
+	html := `<html><body><div class="post">
+		<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<p>Just use the Amazon Go app to enter the store, take the products you want, and go. Read
+		<a href="https://www.wikipedia.org/wiki/Amazon_Go">the history</a> of the store.</p>
+		<h4>You might also like</h4><ul>
+			<li><a href="/2018/01/amazon-books-opens-in-new-york">Amazon Books opens in New York</a></li>
+			<li><a href="https://example.com/news/whole-foods-deal-completed.html">Whole Foods deal completed</a></li>
+			<li><a href="/news/drone-delivery-is-finally-here">Drone delivery is finally here</a></li>
+		</ul></div></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/news/amazon-go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(article.Content, "Drone delivery") {
+		t.Errorf("related articles are not removed: %q", article.Content)
+	}
+
+	if !strings.Contains(article.Content, "the history") {
+		t.Errorf("content is missing: %q", article.Content)
+	}
+}
+
+func TestIsReaderable(t *testing.T) {
+	paragraph := "<p>" + strings.Repeat("Amazon Go is a new kind of store with no checkout required. ", 4) + "</p>"
+
+	tests := []struct {
+		html       string
+		readerable bool
+	}{
+		{"<html><body><article>" + strings.Repeat(paragraph, 3) + "</article></body></html>", true},
+		{"<html><body>" + paragraph + "</body></html>", false},
+		{"<html><body><ul>" + strings.Repeat("<li>"+paragraph+"</li>", 10) + "</ul></body></html>", false},
+		{`<html><body><div style="display:none">` + strings.Repeat(paragraph, 10) + "</div></body></html>", false},
+		{`<html><body><div class="sidebar">` + strings.Repeat(paragraph, 10) + "</div></body></html>", true},
+		{`<html><body>` + strings.Repeat(strings.Replace(paragraph, "<p>", `<p class="comment">`, 1), 10) + "</body></html>", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if readerable := IsReaderable(test.html); readerable != test.readerable {
+			t.Errorf("%.80q: expected readerable %v", test.html, test.readerable)
+		}
+	}
+}
+
+func BenchmarkIsReaderable(b *testing.B) {
+	page, err := ioutil.ReadFile("testdata/messy.html")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	html := string(page)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		IsReaderable(html)
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	html := `<html><head><title>Weekly digest</title></head><body><main>
+		<article><h2>Amazon Go opens</h2>
+			<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+			<p>Just use the Amazon Go app to enter the store, take the products you want, and go, without any cashier.</p></article>
+		<article><h2>Harbour notes</h2>
+			<p>The harbour wakes up long before the tourists arrive, and the fishermen, as always, have sold their catch.</p>
+			<p>By nine, the quay belongs to the ferries, which carry the day-trippers, the cyclists, and the dogs to the islands.</p></article>
+		<article><h2>Too short</h2><p>Nothing to read here, really.</p></article>
+		</main></body></html>`
+
+	articles, err := ParseAll(html, "https://www.example.com/digest", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	titles := []string{}
+	for _, article := range articles {
+		titles = append(titles, article.Meta.Title)
+	}
+
+	if len(titles) != 2 || titles[0] == titles[1] {
+		t.Fatalf("unexpected articles: %q", titles)
+	}
+
+	for _, article := range articles {
+		if article.Meta.Title == "Harbour notes" && !strings.Contains(article.Content, "fishermen") ||
+			article.Meta.Title == "Amazon Go opens" && !strings.Contains(article.Content, "cashier") ||
+			strings.Contains(article.Content, "fishermen") && strings.Contains(article.Content, "cashier") {
+			t.Errorf("unexpected content of %q: %q", article.Meta.Title, article.Content)
+		}
+	}
+
+	articles, err = ParseAll(html, "https://www.example.com/digest", 1)
+	if err != nil || len(articles) != 1 {
+		t.Errorf("expected 1 article, got %d (%v)", len(articles), err)
+	}
+
+	// Articles with the same score are returned in document order
+	paragraphs := `<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<p>Just use the Amazon Go app to enter the store, take the products you want, and go, without any cashier.</p>`
+	html = `<html><body><main><article><h2>First</h2>` + paragraphs + `</article>
+		<article><h2>Second</h2>` + paragraphs + `</article></main></body></html>`
+
+	for i := 0; i < 10; i++ {
+		articles, err = ParseAll(html, "https://www.example.com/digest", 0)
+		if err != nil || len(articles) != 2 || articles[0].Meta.Title != "First" || articles[1].Meta.Title != "Second" {
+			t.Fatalf("articles are not in document order (%v)", err)
+		}
+	}
+}
+
+func TestScoreCandidates(t *testing.T) {
+	html := `<html><body><div id="main"><article class="story">
+		<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<p>Just use the Amazon Go app to enter the store, take the products you want, and go, without any cashier.</p>
+		</article></div><div class="links"><p><a href="/one">One</a>, <a href="/two">two</a> and <a href="/three">three more links</a></p></div>
+		</body></html>`
+
+	candidates, err := ScoreCandidates(html, "https://www.example.com/", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(candidates) < 3 {
+		t.Fatalf("unexpected candidates: %+v", candidates)
+	}
+
+	top := candidates[0]
+	if top.TagName != "article" || top.ClassName != "story" || top.Score <= 0 {
+		t.Errorf("unexpected top candidate: %+v", top)
+	}
+
+	for i := 1; i < len(candidates); i++ {
+		if candidates[i].Score > candidates[i-1].Score {
+			t.Errorf("candidates are not ordered by score: %+v", candidates)
+		}
+	}
+
+	if candidates, _ = ScoreCandidates(html, "https://www.example.com/", 1); len(candidates) != 1 {
+		t.Errorf("unexpected number of candidates: %d", len(candidates))
+	}
+}
+
+func TestErrors(t *testing.T) {
+	if _, err := ParseHTML("  ", "https://www.example.com/"); !errors.Is(err, ErrEmptyHTML) {
+		t.Errorf("expected ErrEmptyHTML, got %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, err := Parse(server.URL, 5*time.Second)
+	if !errors.Is(err, ErrHTTPStatus) {
+		t.Fatalf("expected ErrHTTPStatus, got %v", err)
+	}
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status 404, got %v", err)
+	}
+}
+
+func TestResponseInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><body><p>Amazon Go is a new kind of store with no checkout required.</p></body></html>`))
+	}))
+	defer server.Close()
+
+	article, err := ParseWithClient(server.URL, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if article.StatusCode != http.StatusOK {
+		t.Errorf("unexpected status code: %d", article.StatusCode)
+	}
+
+	if article.ContentType != "text/html; charset=utf-8" {
+		t.Errorf("unexpected content type: %q", article.ContentType)
+	}
+}
+
+func TestInlineImages(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 92)...)
+	var nRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&nRequests, 1)
+		switch r.URL.Path {
+		case "/store.png":
+			if r.Header.Get("Referer") != "https://www.example.com/" {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			w.Write(png)
+		case "/slow.png":
+			select {
+			case <-time.After(time.Second):
+				w.Write(png)
+			case <-r.Context().Done():
+			}
+		case "/gate.png", "/app.png":
+			w.Write(append(png, png...))
+		case "/logo.svg":
+			w.Header().Set("Content-Type", "image/svg+xml")
+			w.Write([]byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	html := `<html><body><article>
+		<p>Amazon Go is a new kind of store with no checkout required.<img src="/store.png" srcset="/store.png 2x"></p>
+		<p>Just use the Amazon Go app to enter the store, take the products you want, and go.<img src="/store.png"></p>
+		<p>Amazon says the cameras don't use facial recognition.<img src="/logo.svg"><img src="/missing.png"><img src="/slow.png"></p>
+		<p>Cameras and weight sensors on the shelves track which products are taken.<img src="/gate.png"><img src="/app.png"></p>
+		</article></body></html>`
+
+	opts := Options{
+		HTTPClient:             server.Client(),
+		Header:                 http.Header{"Referer": {"https://www.example.com/"}},
+		InlineImages:           true,
+		MaxInlineImagesSize:    300,
+		InlineImageConcurrency: 1,
+		InlineImageTimeout:     100 * time.Millisecond,
+	}
+
+	// Images in loopback address are not fetched by default, including
+	// the ones which host name is resolved to it
+	for _, pageURL := range []string{server.URL, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)} {
+		article, err := New(opts).ParseHTML(html, pageURL)
+		if err != nil || strings.Contains(article.RawContent, "data:") || atomic.LoadInt32(&nRequests) != 0 {
+			t.Fatalf("images in loopback address are fetched: %q (%v)", article.RawContent, err)
+		}
+	}
+
+	opts.InlinePrivateImages = true
+	article, err := New(opts).ParseHTML(html, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataURI := `src="data:image/png;base64,iVBORw0KGgo`
+	if strings.Count(article.RawContent, dataURI) != 3 || strings.Contains(article.RawContent, "srcset") {
+		t.Errorf("images are not inlined: %q", article.RawContent)
+	}
+
+	// SVG is never inlined, slow image is given up, and the last image
+	// exceeds the total size so it's not even fetched
+	for _, path := range []string{"/app.png", "/logo.svg", "/missing.png", "/slow.png"} {
+		if !strings.Contains(article.RawContent, `src="`+server.URL+path+`"`) {
+			t.Errorf("image %s is inlined: %q", path, article.RawContent)
+		}
+	}
+
+	if n := atomic.LoadInt32(&nRequests); n != 5 {
+		t.Errorf("expected 5 requests, got %d", n)
+	}
+
+	if len(article.Images) == 0 || article.Images[0].URL != server.URL+"/store.png" ||
+		strings.Contains(article.Markdown, "data:") {
+		t.Errorf("image URLs are replaced outside HTML: %+v", article.Images)
+	}
+
+	// Image bigger than the maximum size is not inlined
+	opts.MaxInlineImageSize = 150
+	article, err = New(opts).ParseHTML(html, server.URL)
+	if err != nil || strings.Count(article.RawContent, dataURI) != 2 {
+		t.Errorf("image bigger than the maximum size is inlined: %q (%v)", article.RawContent, err)
+	}
+}
+
+func TestIsPrivateIP(t *testing.T) {
+	tests := map[string]bool{
+		"127.0.0.1":       true,
+		"::1":             true,
+		"10.0.0.1":        true,
+		"192.168.1.1":     true,
+		"169.254.169.254": true,
+		"0.0.0.0":         true,
+		"8.8.8.8":         false,
+		"2001:4860::8888": false,
+	}
+
+	for ip, expected := range tests {
+		if private := isPrivateIP(net.ParseIP(ip)); private != expected {
+			t.Errorf("isPrivateIP(%q) = %v, want %v", ip, private, expected)
+		}
+	}
+}
+
+func TestRequestHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		text := "Amazon Go is a new kind of store with no checkout required."
+		if r.Header.Get("Accept-Language") == "de" {
+			text = "Amazon Go ist eine neue Art von Geschäft ohne Kasse."
+		}
+
+		if r.UserAgent() != "Mozilla/5.0" {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		w.Write([]byte(`<html><body><p>` + text + `</p></body></html>`))
+	}))
+	defer server.Close()
+
+	header := http.Header{}
+	header.Set("Accept-Language", "de")
+	article, err := ParseWithOptions(server.URL, Options{UserAgent: "Mozilla/5.0", Header: header})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(article.Content, "ohne Kasse") {
+		t.Errorf("header is not sent: %q", article.Content)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	article, err = ParseWithRequest(req, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(article.Content, "no checkout") {
+		t.Errorf("unexpected content: %q", article.Content)
+	}
+}
+
+func TestConnectionReuse(t *testing.T) {
+	var nConnections int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body><p>Amazon Go is a new kind of store with no checkout required.</p></body></html>`))
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&nConnections, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	rd := New(Options{Timeout: 5 * time.Second, ConnectTimeout: time.Second})
+	for i := 0; i < 3; i++ {
+		if _, err := rd.Parse(server.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&nConnections); n != 1 {
+		t.Errorf("expected 1 connection, got %d", n)
+	}
+}
+
+func TestRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/news/2018/amazon-go", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/news/2018/amazon-go", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body><p>Amazon Go is a new kind of store with no checkout required,
+			which means you never have to <a href="queue">wait in line</a>.</p></body></html>`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	article, err := ParseWithOptions(server.URL+"/old", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if article.URL != server.URL+"/news/2018/amazon-go" {
+		t.Errorf("unexpected final URL: %q", article.URL)
+	}
+
+	if !strings.Contains(article.RawContent, `href="`+server.URL+`/news/2018/queue"`) {
+		t.Errorf("link is not resolved from final URL: %q", article.RawContent)
+	}
+
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/old", http.StatusFound)
+	})
+
+	_, err = ParseWithOptions(server.URL+"/loop", Options{MaxRedirects: 1})
+	if !errors.Is(err, ErrTooManyRedirects) {
+		t.Errorf("expected ErrTooManyRedirects, got %v", err)
+	}
+
+	errBlocked := errors.New("redirect is blocked")
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return errBlocked
+		},
+	}
+
+	_, err = ParseWithOptions(server.URL+"/old", Options{HTTPClient: client, MaxRedirects: 5})
+	if !errors.Is(err, errBlocked) {
+		t.Errorf("redirect policy of client is not applied: %v", err)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	withRetryAfter := func(value string) *http.Response {
+		return &http.Response{Header: http.Header{"Retry-After": {value}}}
+	}
+
+	tests := []struct {
+		resp     *http.Response
+		attempt  int
+		backoff  time.Duration
+		expected time.Duration
+	}{
+		{nil, 0, 0, time.Second},
+		{nil, 2, 100 * time.Millisecond, 400 * time.Millisecond},
+		{nil, 10, time.Second, 30 * time.Second},
+		{nil, 100, time.Second, 30 * time.Second},
+		{withRetryAfter("5"), 0, time.Second, 5 * time.Second},
+		{withRetryAfter("86400"), 0, time.Second, 30 * time.Second},
+		{withRetryAfter("99999999999999"), 0, time.Second, 30 * time.Second},
+		{withRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)), 0, time.Second, 30 * time.Second},
+	}
+
+	for _, test := range tests {
+		if delay := retryDelay(test.resp, test.attempt, test.backoff); delay != test.expected {
+			t.Errorf("retryDelay(attempt %d, backoff %v): expected %v, got %v", test.attempt, test.backoff, test.expected, delay)
+		}
+	}
+}
+
+func TestRetries(t *testing.T) {
+	nRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nRequests++
+		switch nRequests {
+		case 1:
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+		default:
+			w.Write([]byte(`<html><body><p>Amazon Go is a new kind of store with no checkout required.</p></body></html>`))
+		}
+	}))
+	defer server.Close()
+
+	_, err := ParseWithOptions(server.URL, Options{})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable || nRequests != 1 {
+		t.Fatalf("request is retried by default: %v (%d requests)", err, nRequests)
+	}
+
+	nRequests = 0
+	opts := Options{MaxRetries: 1, RetryBackoff: time.Millisecond}
+	if _, err = ParseWithOptions(server.URL, opts); !errors.As(err, &statusErr) || nRequests != 2 {
+		t.Errorf("request is retried more than the limit: %v (%d requests)", err, nRequests)
+	}
+
+	nRequests = 0
+	opts.MaxRetries = 3
+	article, err := ParseWithOptions(server.URL, opts)
+	if err != nil || nRequests != 3 || !strings.Contains(article.Content, "no checkout") {
+		t.Errorf("request is not retried: %q (%v, %d requests)", article.Content, err, nRequests)
+	}
+}
+
+func TestNextPageURL(t *testing.T) {
+	tests := []struct {
+		pageURL  string
+		html     string
+		expected string
+	}{
+		{"https://www.example.com/story", `<a href="/story/2">Next</a>`, "https://www.example.com/story/2"},
+		{"https://www.example.com/story.html", `<a href="story-2.html">»</a>`, "https://www.example.com/story-2.html"},
+		{"https://www.example.com/story/page/2", `<link rel="next" href="/story/page/3">`, "https://www.example.com/story/page/3"},
+		{"https://www.example.com/story", `<a rel="next" href="?page=2">2</a>`, "https://www.example.com/story?page=2"},
+
+		// Next post, carousel and comments pagination are not the next page
+		{"https://www.example.com/2018/01/amazon-go/", `<link rel="next" href="/2018/01/another-post/">`, ""},
+		{"https://www.example.com/2018/01/amazon-go/", `<a rel="next" href="/2018/01/amazon-go-review/">Next</a>`, ""},
+		{"https://www.example.com/story", `<div class="carousel"><a href="/gallery/5">›</a></div>`, ""},
+		{"https://www.example.com/story", `<div id="comments"><a href="/story?page=2">Next</a></div>`, ""},
+		{"https://www.example.com/story", `<nav><a href="/story/2">Next</a></nav>`, ""},
+		{"https://www.example.com/story", `<footer><a rel="next" href="/story/2">More</a></footer>`, ""},
+	}
+
+	for _, test := range tests {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(test.html))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pageURL, _ := nurl.Parse(test.pageURL)
+		r := readability{url: pageURL}
+		if nextURL := r.getNextPageURL(doc); nextURL != test.expected {
+			t.Errorf("getNextPageURL(%s) in %s: expected %q, got %q", test.html, test.pageURL, test.expected, nextURL)
+		}
+	}
+}
+
+func TestPagination(t *testing.T) {
+	pages := map[string]string{
+		"/story": `<html><head><link rel="next" href="/story?page=2"></head><body><article>
+			<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+			</article></body></html>`,
+		"/story?page=2": `<html><body><article>
+			<p>Just use the Amazon Go app to enter the store, take the products you want, and go, without any cashier.</p>
+			</article><div class="pages"><a href="/story">1</a> <a href="/story?page=3">Next »</a></div></body></html>`,
+		"/story?page=3": `<html><body><article>
+			<p>Cameras and weight sensors on the shelves track which products are taken, and which are put back again.</p>
+			</article><a href="/story?page=2">Previous</a> <a href="/story">Next</a></body></html>`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(pages[r.URL.RequestURI()]))
+	}))
+	defer server.Close()
+
+	article, err := ParseWithOptions(server.URL+"/story", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if article.Meta.NextPageURL != server.URL+"/story?page=2" || strings.Contains(article.Content, "without any cashier") {
+		t.Errorf("unexpected first page: %q %q", article.Meta.NextPageURL, article.Content)
+	}
+
+	article, err = ParseWithOptions(server.URL+"/story", Options{MaxPages: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(article.Content, "without any cashier") || strings.Contains(article.Content, "weight sensors") ||
+		article.Meta.NextPageURL != server.URL+"/story?page=3" {
+		t.Errorf("unexpected content of two pages: %q %q", article.Meta.NextPageURL, article.Content)
+	}
+
+	// The last page links back to the first page, which must not be fetched again
+	article, err = ParseWithOptions(server.URL+"/story", Options{MaxPages: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Count(article.Content, "never have to wait") != 1 || !strings.HasSuffix(article.Content, "put back again.") {
+		t.Errorf("unexpected content of all pages: %q", article.Content)
+	}
+}
+
+func TestParseContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+			return
+		}
+		w.Write([]byte(`<html><body><p>Amazon Go is a new kind of store with no checkout required.</p></body></html>`))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ParseContext(ctx, server.URL); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := ParseContext(ctx, server.URL); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow-header" {
+			time.Sleep(200 * time.Millisecond)
+		}
+
+		w.Write([]byte(`<html><body><p>Amazon Go is a new kind of store with no checkout required.</p>`))
+		w.(http.Flusher).Flush()
+
+		if r.URL.Path == "/slow-body" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write([]byte(`</body></html>`))
+	}))
+	defer server.Close()
+
+	opts := Options{ResponseHeaderTimeout: 100 * time.Millisecond, Timeout: 5 * time.Second}
+	if _, err := ParseWithOptions(server.URL+"/slow-body", opts); err != nil {
+		t.Errorf("slow body should be read within overall timeout: %v", err)
+	}
+
+	if _, err := ParseWithOptions(server.URL+"/slow-header", opts); err == nil {
+		t.Error("expected error for slow response header")
+	}
+}
+
+func TestContentEncoding(t *testing.T) {
+	gzipped, err := ioutil.ReadFile("testdata/messy.html.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := ioutil.ReadFile("testdata/messy.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var deflated bytes.Buffer
+	writer := zlib.NewWriter(&deflated)
+	writer.Write([]byte(`<html><body><p>Amazon Go is a new kind of store with no checkout required.</p></body></html>`))
+	writer.Close()
+
+	var brotlied bytes.Buffer
+	brWriter := brotli.NewWriter(&brotlied)
+	brWriter.Write([]byte(`<html><body><p>Just use the Amazon Go app to enter the store, and go.</p></body></html>`))
+	brWriter.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gzip":
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gzipped)
+		case "/deflate":
+			w.Header().Set("Content-Encoding", "deflate")
+			w.Write(deflated.Bytes())
+		case "/br":
+			w.Header().Set("Content-Encoding", "br")
+			w.Write(brotlied.Bytes())
+		case "/compress":
+			w.Header().Set("Content-Encoding", "compress")
+			w.Write([]byte{0x1f, 0x9d, 0x90})
+		}
+	}))
+	defer server.Close()
+
+	// Set the header manually, so the client doesn't decompress the body
+	header := http.Header{}
+	header.Set("Accept-Encoding", "gzip, deflate, br")
+	rd := New(Options{Header: header})
+
+	article, err := rd.Parse(server.URL + "/gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if article.Content != strings.TrimSpace(string(expected)) {
+		t.Errorf("unexpected gzipped content:\n%s", article.Content)
+	}
+
+	article, err = rd.Parse(server.URL + "/deflate")
+	if err != nil || !strings.Contains(article.Content, "no checkout required") {
+		t.Errorf("unexpected deflated content: %q (%v)", article.Content, err)
+	}
+
+	article, err = rd.Parse(server.URL + "/br")
+	if err != nil || !strings.Contains(article.Content, "enter the store") {
+		t.Errorf("unexpected Brotli content: %q (%v)", article.Content, err)
+	}
+
+	if _, err := rd.Parse(server.URL + "/compress"); !errors.Is(err, ErrUnsupportedEncoding) {
+		t.Errorf("expected ErrUnsupportedEncoding, got %v", err)
+	}
+}
+
+func TestCharset(t *testing.T) {
+	html := "<html><head><meta charset=\"iso-8859-1\"><title>Caf\xe9 culture in Paris and beyond</title></head>" +
+		"<body><div><p>Le caf\xe9 est un lieu de rencontre tr\xe8s populaire.</p></div></body></html>"
+
+	article, err := ParseReader(strings.NewReader(html), "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if article.Meta.Title != "Café culture in Paris and beyond" {
+		t.Errorf("unexpected title: %q", article.Meta.Title)
+	}
+
+	if !strings.Contains(article.Content, "très populaire") {
+		t.Errorf("unexpected content: %q", article.Content)
+	}
+}
+
+func TestRelaxedRetry(t *testing.T) {
+	html := `<html><body><div id="community"><div>
+		<p>Amazon Go is a new kind of store with no checkout required, which means
+		you never have to wait in line.</p></div></div></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(article.Content, "Amazon Go is a new kind of store") {
+		t.Errorf("unexpected content: %q", article.Content)
+	}
+}
+
+func TestReadTime(t *testing.T) {
+	tests := []struct {
+		text     string
+		min, max int
+	}{
+		{strings.Repeat("你好", 1275), 9, 11},
+		{strings.Repeat("こんにちは", 714), 9, 12},
+		{strings.Repeat("안녕하세요 ", 884), 5, 7},
+		{strings.Repeat("สวัสดีครับ ", 536), 5, 7},
+	}
+
+	for _, test := range tests {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader("<p>" + test.text + "</p>"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := readability{}
+		content := doc.Find("p")
+		min, max := r.estimateReadTime(content, r.detectLanguage(content))
+		if min != test.min || max != test.max {
+			t.Errorf("unexpected read time for %.20q: %d-%d", test.text, min, max)
+		}
+	}
+}
+
+func TestReadSpeed(t *testing.T) {
+	html := "<div><p>" + strings.Repeat("a", 1000) + "</p>" + strings.Repeat(`<img src="/store.jpg">`, 4) + "</div>"
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[time.Duration]int{
+		0:                11,
+		30 * time.Second: 12,
+		-1:               10,
+	}
+
+	for imageReadTime, expected := range tests {
+		r := readability{opts: Options{ReadSpeedCPM: 100, ImageReadTime: imageReadTime}}
+		content := doc.Find("div")
+		min, max := r.estimateReadTime(content, r.detectLanguage(content))
+		if min != expected || max != expected {
+			t.Errorf("unexpected read time with image read time %v: %d-%d", imageReadTime, min, max)
+		}
+	}
+}
+
+func TestPreferSelector(t *testing.T) {
+	html := `<html><body><article>
+		<section><p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p></section>
+		<div><p>Get the newsletter, with the best stories of the week, delivered to your inbox every Sunday morning.</p></div>
+		<section><p>Just use the Amazon Go app to enter the store, take the products you want, and go, without any cashier.</p></section>
+		</article></body></html>`
+
+	article, err := New(Options{PreferSelector: "article section"}).ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(article.Content, "never have to wait") ||
+		!strings.Contains(article.Content, "without any cashier") ||
+		strings.Contains(article.Content, "newsletter") {
+		t.Errorf("unexpected content from selector: %q", article.Content)
+	}
+
+	for _, selector := range []string{"main", "article section:first-child", "<invalid"} {
+		article, err = New(Options{PreferSelector: selector}).ParseHTML(html, "https://www.example.com/")
+		if err != nil || !strings.Contains(article.Content, "newsletter") {
+			t.Errorf("%q: content is not searched as usual: %q (%v)", selector, article.Content, err)
+		}
+	}
+}
+
+func TestRemoveSelectors(t *testing.T) {
+	html := `<html><body><article>
+		<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<div data-consent><p>We use cookies to give you the best experience, and by continuing to browse you agree to it.</p></div>
+		<p>Just use the Amazon Go app to enter the store, take the products you want, and go, without any cashier.</p>
+		<p class="install">Open this page in the app, so you can read it anywhere, even offline, for free.</p>
+		</article></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil || !strings.Contains(article.Content, "cookies") {
+		t.Fatalf("unexpected content: %q (%v)", article.Content, err)
+	}
+
+	opts := Options{RemoveSelectors: []string{"[data-consent]", "p.install", "<invalid"}}
+	article, err = New(opts).ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(article.Content, "cookies") || strings.Contains(article.Content, "offline") ||
+		!strings.Contains(article.Content, "without any cashier") {
+		t.Errorf("unexpected content with removed selectors: %q", article.Content)
+	}
+}
+
+func TestUnlikelyCandidates(t *testing.T) {
+	html := `<html><body><article>
+		<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<div class="sidebar-story"><p>The store was first opened to the employees of Amazon in Seattle, back in December 2016.</p></div>
+		<p>Just use the Amazon Go app to enter the store, take the products you want, and go, without any cashier.</p>
+		<div class="teaser"><p>The technology relies on cameras, sensors and deep learning, just like the self-driving cars.</p></div>
+		</article></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil || strings.Contains(article.Content, "December 2016") || !strings.Contains(article.Content, "self-driving") {
+		t.Fatalf("unexpected content: %q (%v)", article.Content, err)
+	}
+
+	opts := Options{
+		UnlikelyCandidates:   regexp.MustCompile(`(?i)sidebar|teaser`),
+		OkMaybeItsACandidate: regexp.MustCompile(`(?i)story`),
+	}
+
+	article, err = New(opts).ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(article.Content, "December 2016") || strings.Contains(article.Content, "self-driving") ||
+		!strings.Contains(article.Content, "without any cashier") {
+		t.Errorf("unexpected content with custom patterns: %q", article.Content)
+	}
+}
+
+func TestSkipReadTime(t *testing.T) {
+	html := "<html><body><article><p>" + strings.Repeat("Amazon Go is a new kind of store. ", 100) +
+		"</p></article></body></html>"
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil || article.Meta.MinReadTime == 0 {
+		t.Fatalf("read time is not estimated: %+v (%v)", article.Meta, err)
+	}
+
+	article, err = New(Options{SkipReadTime: true}).ParseHTML(html, "https://www.example.com/")
+	if err != nil || article.Meta.MinReadTime != 0 || article.Meta.MaxReadTime != 0 {
+		t.Errorf("read time is estimated: %+v (%v)", article.Meta, err)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	paragraphs := `<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<p>Just use the Amazon Go app to enter the store, take the products you want, and go, without any cashier.</p>`
+
+	tests := []struct {
+		html      string
+		truncated bool
+	}{
+		{`<header><a class="subscribe-link" href="/subscribe">Subscribe</a></header>
+			<article>` + paragraphs + `</article>`, false},
+		{`<article>` + paragraphs + `</article>
+			<div class="article-paywall" style="display:none">Keep reading</div>`, true},
+		{`<article>` + paragraphs + `<p>Subscribe now to continue reading this story.</p></article>`, true},
+		{`<article>` + paragraphs + `</article>
+			<div class="newsletter-subscribe"><input type="email"><button>Sign up</button></div>`, false},
+		{`<script type="application/ld+json">{"@type": "NewsArticle", "isAccessibleForFree": "False"}</script>
+			<article>` + paragraphs + `</article>`, true},
+	}
+
+	for _, test := range tests {
+		article, err := ParseHTML("<html><body>"+test.html+"</body></html>", "https://www.example.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if article.Meta.Truncated != test.truncated {
+			t.Errorf("%.60q: expected truncated %v", test.html, test.truncated)
+		}
+	}
+}
+
+func TestMinContentLength(t *testing.T) {
+	html := `<html><head><meta name="description" content="A store without checkout."></head><body>
+		<div class="teaser"><p>Amazon Go is a new kind of store. Subscribe to read more.</p></div>
+		<div class="community-story"><div>
+		<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<p>Just use the Amazon Go app to enter the store, take the products you want, and go, without any cashier.</p>
+		</div></div></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil || !strings.Contains(article.Content, "Subscribe") {
+		t.Fatalf("unexpected content without minimum length: %q (%v)", article.Content, err)
+	}
+
+	article, err = New(Options{MinContentLength: 100}).ParseHTML(html, "https://www.example.com/")
+	if err != nil || !strings.Contains(article.Content, "without any cashier") {
+		t.Errorf("longer content is not used: %q (%v)", article.Content, err)
+	}
+
+	article, err = New(Options{MinContentLength: 500}).ParseHTML(html, "https://www.example.com/")
+	if !errors.Is(err, ErrContentTooShort) {
+		t.Errorf("expected ErrContentTooShort, got %v", err)
+	}
+
+	if article.Meta.Excerpt != "A store without checkout." {
+		t.Errorf("metadata is not returned: %+v", article.Meta)
+	}
+}
+
+func TestFigureCaptions(t *testing.T) {
+	html := `<html><body><div class="essay"><div>
+		<p>The old harbour of Marseille wakes up long before the tourists arrive.</p>
+		<div class="gallery">
+			<figure><img src="/img/boats.jpg"><figcaption>Fishing boats at dawn</figcaption></figure>
+			<figure><img src="/img/market.jpg"><figcaption>The fish market, <a href="/credits">photo by AFP</a></figcaption></figure>
+			<figure><img src="/img/ferry.jpg"><figcaption>The ferry to Frioul</figcaption></figure>
+		</div></div></div></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/marseille")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(article.Images) != 3 {
+		t.Errorf("expected 3 images, got %v", article.Images)
+	}
+
+	for _, caption := range []string{"Fishing boats at dawn", "photo by AFP", "The ferry to Frioul"} {
+		if !strings.Contains(article.Content, caption) {
+			t.Errorf("caption %q is removed: %q", caption, article.Content)
+		}
+	}
+}
+
+func TestCleanThresholds(t *testing.T) {
+	html := `<html><body><article>
+		<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<div><img src="/shelf.jpg"><img src="/gate.jpg"><img src="/app.jpg"><p>Inside the store, where the shelves are always full.</p></div>
+		<p>Just use the Amazon Go app to enter the store, take the products you want, and go, without any cashier.</p>
+		</article></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil || len(article.Images) != 0 {
+		t.Fatalf("image gallery is not removed by default: %+v (%v)", article.Images, err)
+	}
+
+	opts := Options{CleanThresholds: CleanThresholds{MinParagraphImageRatio: -1}}
+	article, err = New(opts).ParseHTML(html, "https://www.example.com/")
+	if err != nil || len(article.Images) != 3 {
+		t.Errorf("image gallery is removed: %+v (%v)", article.Images, err)
+	}
+}
+
+func TestLightClean(t *testing.T) {
+	html := `<html><body><nav><a href="/">Home</a> <a href="/news">News</a></nav><article class="story">
+		<p class="lede" style="font-size: 2em">Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<p>Just use the <a href="/app">Amazon Go app</a> to enter the store, take the products you want, and go, without any cashier.</p>
+		</article></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil || strings.Contains(article.RawContent, "lede") {
+		t.Fatalf("content is not cleaned by default: %q (%v)", article.RawContent, err)
+	}
+
+	article, err = New(Options{LightClean: true}).ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{`<article class="story">`, `<p class="lede" style="font-size: 2em">`,
+		`href="https://www.example.com/app"`} {
+		if !strings.Contains(article.RawContent, expected) {
+			t.Errorf("%s is not kept: %q", expected, article.RawContent)
+		}
+	}
+
+	if strings.Contains(article.RawContent, "Home") {
+		t.Errorf("navigation is not removed: %q", article.RawContent)
+	}
+}
+
+func TestFlattenWrappers(t *testing.T) {
+	html := `<html><body>` + strings.Repeat("<div>", 30) + `<div class="story"><div>
+		<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<div><div><p>Just use the Amazon Go app to enter the store, take the products you want, and go, without any cashier.</p></div></div>
+		</div></div><div class="sidebar"><div><p>Sign up to our newsletter to get the latest news about the store.</p></div></div>` +
+		strings.Repeat("</div>", 30) + `</body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flattened, err := New(Options{FlattenWrappers: true}).ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if flattened.Content != article.Content {
+		t.Errorf("content is changed by flattening: %q, want %q", flattened.Content, article.Content)
+	}
+
+	if strings.Count(flattened.RawContent, "<div") >= strings.Count(article.RawContent, "<div") {
+		t.Errorf("wrappers are not flattened: %q", flattened.RawContent)
+	}
+
+	if strings.Contains(flattened.Content, "newsletter") {
+		t.Errorf("sidebar is not removed: %q", flattened.Content)
+	}
+}
+
+func TestVoidElements(t *testing.T) {
+	html := `<html><body><article>
+		<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<hr>
+		<p>Just use the Amazon Go app to enter the store,<br>take the products you want, and go, without any cashier.</p>
+		</article></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(article.RawContent, "<hr/>") || !strings.Contains(article.RawContent, "<br />") {
+		t.Errorf("void elements are removed: %q", article.RawContent)
+	}
+
+	if !strings.Contains(article.Content, "store,\ntake the products") {
+		t.Errorf("line break is removed from text: %q", article.Content)
+	}
+}
+
+func TestImageOnlyContainers(t *testing.T) {
+	html := `<html><body><article>
+		<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<p><span><img src="/store.jpg"></span></p>
+		<div><picture><source srcset="/gate.webp"><img src="/gate.jpg"></picture></div>
+		<figure><iframe src="https://www.youtube.com/embed/NrmMk1Myrxc"></iframe></figure>
+		<p><em><b></b></em><br></p>
+		<p>Just use the Amazon Go app to enter the store, take the products you want, and go, without any cashier.</p>
+		</article></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, media := range []string{`src="https://www.example.com/store.jpg"`, `src="https://www.example.com/gate.`, "<iframe"} {
+		if !strings.Contains(article.RawContent, media) {
+			t.Errorf("media %s is removed: %q", media, article.RawContent)
+		}
+	}
+
+	if strings.Contains(article.RawContent, "<em>") {
+		t.Errorf("empty element is kept: %q", article.RawContent)
+	}
+}
+
+func TestShortPage(t *testing.T) {
+	pages := map[string]string{
+		`<html><body><div class="main"><div>
+		<img src="/store.jpg"><img src="/gate.jpg"><img src="/app.jpg">
+		<p>The store opens to the public on Monday.</p>
+		</div></div></body></html>`: "The store opens to the public on Monday.",
+
+		`<html><body><div><img src="/store.jpg">
+		<p>The store opens <a href="/opening">to the public on Monday at the Seattle headquarters</a>.</p>
+		</div></body></html>`: "The store opens to the public on Monday at the Seattle headquarters.",
+	}
+
+	for html, content := range pages {
+		article, err := ParseHTML(html, "https://www.example.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if article.Content != content {
+			t.Errorf("unexpected content: %q", article.Content)
+		}
+	}
+}
+
+func TestDataTables(t *testing.T) {
+	html := `<html><body><div class="article"><div>
+		<p>The company reported its quarterly results on Thursday, beating the analyst expectations.</p>
+		<table>
+			<tr><th>Q</th><th>EPS</th></tr>
+			<tr><td>Q1</td><td>1.20</td></tr>
+			<tr><td>Q2</td><td>1.45</td></tr>
+		</table>
+		<table role="presentation"><tr><td><a href="/share">Share</a></td><td><a href="/tweet">Tweet</a></td></tr></table>
+		<table>
+			<caption>Dividends</caption>
+			<tr><td><a href="/q1">Q1</a></td><td>0.25</td></tr>
+		</table>
+		</div></div></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(article.RawContent, "<th>EPS</th>") || !strings.Contains(article.RawContent, "1.45") {
+		t.Errorf("data table is removed: %q", article.RawContent)
+	}
+
+	if !strings.Contains(article.RawContent, "Dividends") || !strings.Contains(article.RawContent, "0.25") {
+		t.Errorf("captioned table is removed: %q", article.RawContent)
+	}
+
+	if strings.Contains(article.RawContent, "Tweet") {
+		t.Errorf("layout table is kept: %q", article.RawContent)
+	}
+}
+
+func TestEmptyTableCells(t *testing.T) {
+	html := `<html><body><article>
+		<p>The company reported its quarterly results on Thursday, beating the analyst expectations.</p>
+		<table>
+			<tr><th></th><th>Q1</th><th>Q2</th></tr>
+			<tr><td>Revenue</td><td>&nbsp;</td><td>12</td></tr>
+			<tr><td>Profit</td><td>3</td><td>5</td></tr>
+		</table>
+		</article></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Count(article.RawContent, "<th") != 3 || strings.Count(article.RawContent, "<td") != 6 {
+		t.Errorf("empty cells are removed: %q", article.RawContent)
+	}
+
+	if !strings.Contains(article.Content, "| Revenue |  | 12 |") {
+		t.Errorf("cells are shifted: %q", article.Content)
+	}
+}
+
+func TestTableText(t *testing.T) {
+	html := `<table><caption>Quarterly results</caption>
+		<tr><th>Quarter</th><th>Revenue</th></tr>
+		<tr><td>Q1</td><td>$51.0 | $49.8B</td></tr>
+		<tr><td>Q2</td><td>$52.9B</td></tr></table>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := readability{}
+	text := r.getTextContent(doc.Find("body"))
+	expected := "Quarterly results\n\n" +
+		"| Quarter | Revenue |\n| --- | --- |\n| Q1 | $51.0 \\| $49.8B |\n| Q2 | $52.9B |"
+	if text != expected {
+		t.Errorf("unexpected text:\n%s", text)
+	}
+}
+
+func TestBlockquoteText(t *testing.T) {
+	html := `<p>He said:</p><blockquote><p>We built the store
+		of the future.</p><p>No lines,</p><blockquote>no checkout.</blockquote></blockquote>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := readability{}
+	text := r.getTextContent(doc.Find("body"))
+	expected := "He said:\n\n> We built the store of the future.\n>\n> No lines,\n>\n> > no checkout."
+	if text != expected {
+		t.Errorf("unexpected text:\n%s", text)
+	}
+}
+
+func TestListText(t *testing.T) {
+	html := `<p>Ingredients:</p><ul><li>Flour</li><li>Eggs
+		<ol><li>Beat them</li><li>Add <b>sugar</b></li></ol></li></ul>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := readability{}
+	text := r.getTextContent(doc.Find("body"))
+	expected := "Ingredients:\n\n- Flour\n- Eggs\n  1. Beat them\n  2. Add sugar"
+	if text != expected {
+		t.Errorf("unexpected text:\n%s", text)
+	}
+}
+
+func TestInlineText(t *testing.T) {
+	html := `<div>Read <a href="/history">the <b>history</b></a> of the store,
+		or <em>visit</em>it.<br>Open daily.<span>  </span><p>Next paragraph</p></div>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := readability{}
+	text := r.getTextContent(doc.Find("body"))
+	expected := "Read the history of the store, or visitit.\nOpen daily.\n\nNext paragraph"
+	if text != expected {
+		t.Errorf("unexpected text: %q", text)
+	}
+}
+
+func TestJoinLines(t *testing.T) {
+	html := `<div><p>The committee met in the<br>morning to discuss the inter-<br>national
+		agreement, and the trade-
+		off it brings.</p><p>Then it</p><p>adjourned.</p><p>A new day.</p>
+		<pre>x := 1
+y := 2</pre></div>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := readability{}
+	text := r.getTextContent(doc.Find("body"))
+	if !strings.Contains(text, "the\nmorning") || !strings.Contains(text, "inter-\nnational") {
+		t.Errorf("lines are joined by default: %q", text)
+	}
+
+	r = readability{opts: Options{JoinLines: true}}
+	text = r.getTextContent(doc.Find("body"))
+	expected := "The committee met in the morning to discuss the international agreement, " +
+		"and the tradeoff it brings.\n\nThen it adjourned.\n\nA new day.\n\nx := 1\ny := 2"
+	if text != expected {
+		t.Errorf("unexpected joined text: %q", text)
+	}
+
+	// Only paragraph text is joined, never the headings, preformatted text,
+	// lists, tables or quotes before or after it
+	tests := map[string]string{
+		`<h2>Introduction</h2><p>iPhone sales grew.</p>`:                  "Introduction\n\niPhone sales grew.",
+		`<p>The sales grew in</p><h2>europe and asia</h2>`:                "The sales grew in\n\neurope and asia",
+		`<pre>x := a</pre><p>and then it runs.</p>`:                       "x := a\n\nand then it runs.",
+		`<ul><li>apples</li></ul><p>and more.</p>`:                        "- apples\n\nand more.",
+		`<table><tr><td>apples</td></tr></table><p>and more.</p>`:         "| apples |\n\nand more.",
+		`<blockquote><p>It's like magic</p></blockquote><p>said one.</p>`: "> It's like magic\n\nsaid one.",
+	}
+
+	for html, expected := range tests {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if text := r.getTextContent(doc.Find("body")); text != expected {
+			t.Errorf("unexpected joined text of %s: %q", html, text)
+		}
+	}
+}
+
+func TestSeparatorLikeText(t *testing.T) {
+	html := `<div><p>Press |X| to close the window.</p><p>Use |X||X| for both panes.</p>
+		<pre>|X|
+|X|</pre></div>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := readability{}
+	text := r.getTextContent(doc.Find("body"))
+	expected := "Press |X| to close the window.\n\nUse |X||X| for both panes.\n\n|X|\n|X|"
+	if text != expected {
+		t.Errorf("unexpected text: %q", text)
+	}
+}
+
+func TestDuplicateCandidates(t *testing.T) {
+	duplicate := `<div><p>Subscribe to our newsletter to get the latest stories delivered
+		to your inbox every morning, before your first cup of coffee.</p></div>`
+	html := `<html><body><main>` + duplicate + duplicate + `
+		<div><p>The harbour wakes up long before the tourists arrive, and the fishermen, as always,
+		have already sold most of their catch by the time the cafes open.</p></div>
+		</main></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if article.Content != "The harbour wakes up long before the tourists arrive, and the fishermen, as always, "+
+		"have already sold most of their catch by the time the cafes open." {
+		t.Errorf("unexpected content: %q", article.Content)
+	}
+}
+
+func TestMergeSiblings(t *testing.T) {
+	html := `<html><body><main>
+		<div class="story-body"><p>The harbour wakes up long before the tourists arrive, and the fishermen,
+		as always, have already sold most of their catch by the time the cafes open.</p>
+		<p>By nine, the quay belongs to the ferries, which carry the day-trippers to the islands.</p></div>
+		<div class="ad"><a href="/ads">Advertisement</a></div>
+		<div class="story-body"><p>In the afternoon, the old men gather under the plane trees, arguing about
+		football, politics, and the price of sardines, which has doubled in a decade.</p></div>
+		</main></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(article.Content, "The harbour wakes up") || !strings.Contains(article.Content, "the old men gather") {
+		t.Errorf("split article is not merged: %q", article.Content)
+	}
+
+	if strings.Contains(article.Content, "Advertisement") {
+		t.Errorf("unrelated sibling is merged: %q", article.Content)
+	}
+}
+
+func TestTextContentGolden(t *testing.T) {
+	page, err := os.Open("testdata/messy.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer page.Close()
+
+	expected, err := ioutil.ReadFile("testdata/messy.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	article, err := ParseReader(page, "https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if article.Content != strings.TrimSpace(string(expected)) {
+		t.Errorf("unexpected content:\n%s", article.Content)
+	}
+}
+
+func TestDebugInfo(t *testing.T) {
+	html := `<html><body><article>
+		<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<p>Just use the Amazon Go app to enter the store, take the products you want, and go.</p>
+		</article></body></html>`
+
+	article, err := New(Options{}).ParseHTML(html, "https://www.example.com/amazon-go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if article.Debug != nil {
+		t.Errorf("debug info is filled when disabled: %+v", article.Debug)
+	}
+
+	article, err = New(Options{Debug: true}).ParseHTML(html, "https://www.example.com/amazon-go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if article.Debug == nil {
+		t.Fatal("debug info is missing")
+	}
+
+	if article.Debug.TagName != "article" || article.Debug.Score <= 0 || article.Debug.Candidates == 0 {
+		t.Errorf("unexpected debug info: %+v", article.Debug)
+	}
+}
+
+func TestAMPPages(t *testing.T) {
+	html := `<html amp><head><style amp-boilerplate>body{visibility:hidden}</style></head><body><article>
+		<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<amp-img src="/images/store.jpg" alt="The store" width="800" height="600" layout="responsive">
+			<amp-img fallback src="/images/store-small.jpg" width="400" height="300"></amp-img>
+		</amp-img>
+		<p>Just use the Amazon Go app to enter the store, take the products you want, and go.</p>
+		<amp-video src="/videos/store.mp4" width="800" height="600" controls></amp-video>
+		</article></body></html>`
+
+	article, err := ParseHTML(html, "https://www.example.com/amp/amazon-go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Image{{URL: "https://www.example.com/images/store.jpg", Alt: "The store"}}
+	if len(article.Images) != 1 || article.Images[0] != expected[0] {
+		t.Errorf("unexpected images: %+v", article.Images)
+	}
+
+	if !strings.Contains(article.RawContent, `<video src="https://www.example.com/videos/store.mp4"`) {
+		t.Errorf("video is missing: %q", article.RawContent)
+	}
+
+	if strings.Contains(article.RawContent, "amp-") {
+		t.Errorf("AMP element is not converted: %q", article.RawContent)
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	html := `<html><body><article>
+		<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<p><img src="images/store.jpg"> Just use the app to enter the store, take the products you want, and go.</p>
+		</article></body></html>`
+
+	dir, err := ioutil.TempDir("", "readability")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "amazon-go.html")
+	if err := ioutil.WriteFile(path, []byte(html), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	article, err := ParseFile(path, "https://www.example.com/2018/amazon-go.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(article.RawContent, "https://www.example.com/2018/images/store.jpg") {
+		t.Errorf("relative URL is not resolved using base URL: %q", article.RawContent)
+	}
+
+	fileURL := "file://" + filepath.ToSlash(path)
+	article, err = ParseFile(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if article.URL != fileURL || !strings.Contains(article.Content, "never have to wait") {
+		t.Errorf("unexpected article without base URL: %q %q", article.URL, article.Content)
+	}
+
+	article, err = ParseFile(fileURL, "")
+	if err != nil || article.URL != fileURL || !strings.Contains(article.Content, "never have to wait") {
+		t.Errorf("unexpected article from file URL: %q %q (%v)", article.URL, article.Content, err)
+	}
+
+	if _, err := ParseFile("file://server/share/amazon-go.html", ""); err == nil {
+		t.Errorf("file URL in other host is read")
+	}
+
+	// Local files are never read through Parse, since its URL might come from user
+	if _, err := New(Options{}).Parse(fileURL); err == nil {
+		t.Errorf("file URL is read by Parse")
+	}
+
+	if _, err := ParseFile(filepath.Join(dir, "missing.html"), ""); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+}
+
+func TestParseFragment(t *testing.T) {
+	fragment := `<h2>How it works</h2>
+		<p>Just use the <a href="/app">Amazon Go app</a> to enter the store, and go.</p>
+		<script>track()</script><img src="images/store.jpg" alt="The store">
+		<p class="share">Share</p>`
+
+	article, err := ParseFragment(fragment, "https://www.example.com/2018/amazon-go.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if article.Meta.Title != "" {
+		t.Errorf("title is extracted from fragment: %q", article.Meta.Title)
+	}
+
+	expected := "How it works\n\nJust use the Amazon Go app to enter the store, and go.\n\nShare"
+	if article.Content != expected {
+		t.Errorf("unexpected content: %q", article.Content)
+	}
+
+	if !strings.Contains(article.RawContent, `href="https://www.example.com/app"`) ||
+		!strings.Contains(article.RawContent, `src="https://www.example.com/2018/images/store.jpg"`) ||
+		strings.Contains(article.RawContent, "track()") {
+		t.Errorf("unexpected HTML content: %q", article.RawContent)
+	}
+
+	if _, err := ParseFragment("<p> </p>", "https://www.example.com/"); !errors.Is(err, ErrNoContent) {
+		t.Errorf("expected ErrNoContent, got %v", err)
+	}
+}
+
+func TestParseDocument(t *testing.T) {
+	html := `<html><head><title>Inside Amazon Go, a store of the future</title></head>
+		<body><div class="article"><p>Amazon Go is a new kind of store with no checkout required,
+		which means you never have to <a href="/queue">wait in line</a>.</p></div></body></html>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	article, err := ParseDocument(doc, "https://www.example.com/news/amazon-go.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if article.Meta.Title != "Inside Amazon Go, a store of the future" {
+		t.Errorf("unexpected title: %q", article.Meta.Title)
+	}
+
+	if !strings.Contains(article.RawContent, `href="https://www.example.com/queue"`) {
+		t.Errorf("unexpected content: %q", article.RawContent)
+	}
+}
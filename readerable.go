@@ -0,0 +1,106 @@
+package readability
+
+import (
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"math"
+	"strings"
+)
+
+const (
+	// readerableMinLength is the minimum number of characters for a paragraph
+	// to be counted when checking whether a page is readerable.
+	readerableMinLength = 140
+
+	// readerableMinScore is the minimum score of the paragraphs for a page
+	// to be considered readerable.
+	readerableMinScore = 20
+)
+
+// IsReaderable quickly checks whether the page probably has an article that
+// worth extracting, e.g. to skip index or navigation pages before parsing
+// them. It only looks at the visible paragraphs with enough text, without the
+// full scoring, so it's much cheaper than parsing. Like the scoring, it might
+// be wrong for some pages.
+func IsReaderable(rawHTML string) bool {
+	doc, err := html.Parse(strings.NewReader(rawHTML))
+	if err != nil {
+		return false
+	}
+
+	score := 0.0
+	var f func(*html.Node) bool
+	f = func(n *html.Node) bool {
+		if n.Type == html.ElementNode {
+			if !isReaderableVisible(n) || n.DataAtom == atom.Script || n.DataAtom == atom.Style {
+				return false
+			}
+
+			// Paragraph inside list item is usually part of navigation or teaser
+			if n.DataAtom == atom.Li {
+				return false
+			}
+
+			if isReaderableNode(n) {
+				matchString := attrOr(n, "class", "") + " " + attrOr(n, "id", "")
+				isUnlikely := unlikelyCandidates.MatchString(matchString) &&
+					!okMaybeItsACandidate.MatchString(matchString)
+
+				length := StrLen(strings.TrimSpace(nodeText(n)))
+				if !isUnlikely && length >= readerableMinLength {
+					score += math.Sqrt(float64(length - readerableMinLength))
+					if score > readerableMinScore {
+						return true
+					}
+				}
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if f(c) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return f(doc)
+}
+
+// isReaderableNode checks if the node could be a paragraph, i.e. <p>, <pre>
+// or <article>, or a <div> that uses <br> to separate its paragraphs.
+func isReaderableNode(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.P, atom.Pre, atom.Article:
+		return true
+	case atom.Div:
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.DataAtom == atom.Br {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isReaderableVisible checks if the node is not hidden by its attributes.
+func isReaderableVisible(n *html.Node) bool {
+	for _, attr := range n.Attr {
+		switch attr.Key {
+		case "hidden":
+			return false
+		case "style":
+			if isHiddenByStyle(attr.Val) {
+				return false
+			}
+		case "aria-hidden":
+			if attr.Val == "true" && !strings.Contains(attrOr(n, "class", ""), "fallback-image") {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,128 @@
+package readability
+
+import (
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"strings"
+)
+
+var (
+	// sanitizedElements is the elements that removed along with their content
+	// when sanitizing, since they can run script or load external content.
+	sanitizedElements = map[atom.Atom]struct{}{
+		atom.Script: {}, atom.Style: {}, atom.Iframe: {}, atom.Frame: {},
+		atom.Frameset: {}, atom.Object: {}, atom.Embed: {}, atom.Applet: {},
+		atom.Form: {}, atom.Input: {}, atom.Button: {}, atom.Textarea: {},
+		atom.Select: {}, atom.Base: {}, atom.Meta: {}, atom.Link: {},
+		atom.Svg: {}, atom.Math: {}, atom.Template: {}, atom.Noscript: {},
+	}
+
+	// allowedElements is the elements that kept as it is when sanitizing.
+	// The other elements are unwrapped, i.e. only their content are kept.
+	allowedElements = map[atom.Atom]struct{}{
+		atom.A: {}, atom.Abbr: {}, atom.Address: {}, atom.Article: {}, atom.Aside: {},
+		atom.Audio: {}, atom.B: {}, atom.Bdi: {}, atom.Bdo: {}, atom.Blockquote: {},
+		atom.Br: {}, atom.Caption: {}, atom.Cite: {}, atom.Code: {}, atom.Col: {},
+		atom.Colgroup: {}, atom.Dd: {}, atom.Del: {}, atom.Details: {}, atom.Dfn: {},
+		atom.Div: {}, atom.Dl: {}, atom.Dt: {}, atom.Em: {}, atom.Figcaption: {},
+		atom.Figure: {}, atom.H1: {}, atom.H2: {}, atom.H3: {}, atom.H4: {},
+		atom.H5: {}, atom.H6: {}, atom.Hr: {}, atom.I: {}, atom.Img: {},
+		atom.Ins: {}, atom.Kbd: {}, atom.Li: {}, atom.Mark: {}, atom.Ol: {},
+		atom.P: {}, atom.Picture: {}, atom.Pre: {}, atom.Q: {}, atom.S: {},
+		atom.Samp: {}, atom.Section: {}, atom.Small: {}, atom.Source: {}, atom.Span: {},
+		atom.Strong: {}, atom.Sub: {}, atom.Summary: {}, atom.Sup: {}, atom.Table: {},
+		atom.Tbody: {}, atom.Td: {}, atom.Tfoot: {}, atom.Th: {}, atom.Thead: {},
+		atom.Time: {}, atom.Tr: {}, atom.Track: {}, atom.U: {}, atom.Ul: {},
+		atom.Var: {}, atom.Video: {}, atom.Wbr: {},
+	}
+
+	// allowedAttributes is the attributes that kept when sanitizing.
+	allowedAttributes = map[string]struct{}{
+		"href": {}, "src": {}, "alt": {}, "title": {}, "width": {}, "height": {},
+		"colspan": {}, "rowspan": {}, "scope": {}, "headers": {}, "start": {},
+		"reversed": {}, "datetime": {}, "cite": {}, "lang": {}, "dir": {},
+		"controls": {}, "poster": {}, "type": {}, "id": {}, "name": {}, "open": {},
+	}
+
+	// urlAttributes is the attributes which value is an URL, so their scheme
+	// must be checked as well.
+	urlAttributes = map[string]struct{}{
+		"href": {}, "src": {}, "cite": {}, "poster": {},
+	}
+)
+
+// Sanitize the content using allowlist of elements and attributes, so it can
+// be rendered safely. Elements which can run script or load external content
+// are removed, event handlers are dropped, and URL with dangerous scheme like
+// javascript: is removed.
+func (r *readability) sanitize(content *goquery.Selection) {
+	for _, n := range content.Nodes {
+		r.sanitizeNode(n)
+	}
+}
+
+// Sanitize the children of node recursively.
+func (r *readability) sanitizeNode(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+
+		switch c.Type {
+		case html.CommentNode:
+			n.RemoveChild(c)
+
+		case html.ElementNode:
+			r.sanitizeNode(c)
+
+			if _, dangerous := sanitizedElements[c.DataAtom]; dangerous {
+				n.RemoveChild(c)
+			} else if _, allowed := allowedElements[c.DataAtom]; !allowed {
+				// Unwrap the element by moving its children to its place
+				for gc := c.FirstChild; gc != nil; gc = c.FirstChild {
+					c.RemoveChild(gc)
+					n.InsertBefore(gc, c)
+				}
+				n.RemoveChild(c)
+			} else {
+				r.sanitizeAttributes(c)
+			}
+		}
+
+		c = next
+	}
+}
+
+// Remove the attributes of node which not in allowlist, including the event
+// handlers and URL with unsafe scheme.
+func (r *readability) sanitizeAttributes(n *html.Node) {
+	attrs := n.Attr[:0]
+	for _, attr := range n.Attr {
+		key := strings.ToLower(attr.Key)
+		if attr.Namespace != "" || strings.HasPrefix(key, "on") {
+			continue
+		}
+
+		_, allowed := allowedAttributes[key]
+		if !allowed {
+			for _, preservedName := range r.opts.PreserveAttributes {
+				if strings.EqualFold(key, preservedName) {
+					allowed = true
+					break
+				}
+			}
+		}
+
+		if !allowed {
+			continue
+		}
+
+		isImage := n.DataAtom == atom.Img || key == "poster"
+		if _, isURL := urlAttributes[key]; isURL && !isSafeURL(attr.Val, isImage) {
+			continue
+		}
+
+		attrs = append(attrs, attr)
+	}
+
+	n.Attr = attrs
+}
@@ -0,0 +1,34 @@
+package readability
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	html := `<html><body><div class="post">
+		<p>Amazon Go is a new kind of store with no checkout required, which means you never have to wait in line.</p>
+		<p onmouseover="steal()">Just use the <a href="javascript:alert(1)">Amazon Go app</a> to enter the store,
+		take the <a href=" JAVA&#9;SCRIPT:alert(2)">products</a> you want, and <a href="/go" onclick="steal()">go</a>.</p>
+		<p>Write <code>&lt;script&gt;alert(3)&lt;/script&gt;</code> to run a script<svg onload="steal()"></svg>.
+		<img src="/images/store.jpg" onerror="steal()" alt="Store"><img src="data:text/html;base64,PHNjcmlwdD4="></p>
+		</div></body></html>`
+
+	article, err := New(Options{Sanitize: true}).ParseHTML(html, "https://www.example.com/news/amazon-go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, unsafe := range []string{"javascript", "JAVA", "steal", "<script", "<svg", "data:text"} {
+		if strings.Contains(article.RawContent, unsafe) {
+			t.Errorf("unsafe %q is not removed: %q", unsafe, article.RawContent)
+		}
+	}
+
+	for _, safe := range []string{`href="https://www.example.com/go"`, `src="https://www.example.com/images/store.jpg"`,
+		"&lt;script&gt;", "Amazon Go app"} {
+		if !strings.Contains(article.RawContent, safe) {
+			t.Errorf("safe %q is removed: %q", safe, article.RawContent)
+		}
+	}
+}
@@ -1,24 +1,121 @@
 package readability
 
 import (
-	"crypto/md5"
-	"fmt"
-	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 	"unicode/utf8"
 )
 
-func hashStr(node *goquery.Selection) string {
-	if node == nil {
-		return ""
+// timeLayouts is list of time format that commonly used in web pages.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"2006/01/02",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC850,
+	time.ANSIC,
+	"January 2, 2006 15:04",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"2 Jan 2006",
+}
+
+// StrLen returns the number of characters in the string. It counts runes
+// instead of bytes, so a CJK character is counted as one character. This is
+// the length used by this package, e.g. for MinParagraphLength.
+func StrLen(str string) int {
+	return utf8.RuneCountInString(str)
+}
+
+// truncateText cuts the string to at most maxLength characters and appends
+// an ellipsis. It's cut on word boundary when possible, but text without
+// spaces like CJK is simply cut on character boundary.
+func truncateText(str string, maxLength int) string {
+	runes := []rune(str)
+	if maxLength <= 0 || len(runes) <= maxLength {
+		return str
+	}
+
+	runes = runes[:maxLength]
+	for i := len(runes) - 1; i > maxLength/2; i-- {
+		if unicode.IsSpace(runes[i]) {
+			runes = runes[:i]
+			break
+		}
 	}
 
-	html, _ := node.Html()
-	return fmt.Sprintf("%x", md5.Sum([]byte(html)))
+	truncated := strings.TrimRightFunc(string(runes), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	})
+
+	return truncated + "…"
 }
 
-func strLen(str string) int {
-	return utf8.RuneCountInString(str)
+// countCommas returns the number of commas in the string, including the
+// non-latin commas like the fullwidth comma (，) that used in CJK text.
+func countCommas(str string) int {
+	count := 0
+	for _, r := range str {
+		switch r {
+		case ',', '،', '﹐', '︐', '︑', '⹁', '⸴', '⸲', '，':
+			count++
+		}
+	}
+
+	return count
+}
+
+// scriptLanguage returns the language of text that mostly written in script
+// without word separator, i.e. Chinese, Japanese, Korean or Thai. It returns
+// empty string for the other text. Japanese is told apart from Chinese by the
+// kana, since both of them use Han characters.
+func scriptLanguage(str string) string {
+	var nLetter, nHan, nKana, nHangul, nThai int
+	for _, r := range str {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+
+		nLetter++
+		switch {
+		case unicode.Is(unicode.Han, r):
+			nHan++
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			nKana++
+		case unicode.Is(unicode.Hangul, r):
+			nHangul++
+		case unicode.Is(unicode.Thai, r):
+			nThai++
+		}
+	}
+
+	switch {
+	case nLetter == 0:
+		return ""
+	case nHangul*2 > nLetter:
+		return "kor"
+	case nThai*2 > nLetter:
+		return "tha"
+	case (nHan+nKana)*2 > nLetter && nKana*10 > nHan+nKana:
+		return "jpn"
+	case (nHan+nKana)*2 > nLetter:
+		return "cmn"
+	default:
+		return ""
+	}
 }
 
 func findSeparator(str string, separators ...string) (int, string) {
@@ -54,6 +151,346 @@ func removeSeparator(str string, separators ...string) string {
 	return strings.Join(finalWords, " ")
 }
 
-func normalizeText(str string) string {
+// NormalizeText collapses the whitespace in the string into a single space and
+// trims the leading and trailing ones. It's the normalization used by this
+// package for text, so it can be used to post-process the content consistently.
+func NormalizeText(str string) string {
 	return strings.Join(strings.Fields(str), " ")
 }
+
+func parseTime(str string) (time.Time, bool) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, str); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+var (
+	safeURLSchemes = regexp.MustCompile(`(?i)^(https?|mailto|ftp|tel):`)
+	anyURLScheme   = regexp.MustCompile(`^[^/?#]*:`)
+	dataImageURL   = regexp.MustCompile(`(?i)^data:image/(png|jpe?g|gif|webp|avif);base64,`)
+)
+
+// phrasingElements is the elements that can be part of a paragraph.
+var phrasingElements = map[atom.Atom]struct{}{
+	atom.Abbr: {}, atom.Audio: {}, atom.B: {}, atom.Bdo: {}, atom.Br: {},
+	atom.Button: {}, atom.Cite: {}, atom.Code: {}, atom.Data: {}, atom.Datalist: {},
+	atom.Dfn: {}, atom.Em: {}, atom.Embed: {}, atom.I: {}, atom.Img: {},
+	atom.Input: {}, atom.Kbd: {}, atom.Label: {}, atom.Mark: {}, atom.Math: {},
+	atom.Meter: {}, atom.Noscript: {}, atom.Object: {}, atom.Output: {}, atom.Progress: {},
+	atom.Q: {}, atom.Ruby: {}, atom.Samp: {}, atom.Script: {}, atom.Select: {},
+	atom.Small: {}, atom.Span: {}, atom.Strong: {}, atom.Sub: {}, atom.Sup: {},
+	atom.Textarea: {}, atom.Time: {}, atom.Var: {}, atom.Wbr: {},
+}
+
+// voidElements is the elements that can't have any content.
+var voidElements = map[atom.Atom]struct{}{
+	atom.Area: {}, atom.Base: {}, atom.Br: {}, atom.Col: {}, atom.Embed: {},
+	atom.Hr: {}, atom.Img: {}, atom.Input: {}, atom.Link: {}, atom.Meta: {},
+	atom.Param: {}, atom.Source: {}, atom.Track: {}, atom.Wbr: {},
+}
+
+// mediaElements is the selector for elements that are content by themselves
+// even without any text, e.g. image, video and embedded frame. Horizontal
+// rule is included as well, since it's used to separate sections.
+const mediaElements = "img,picture,video,audio,iframe,embed,object,svg,canvas,hr"
+
+// isPhrasingContent checks if the node is a phrasing content, i.e. text or
+// inline element. Links and edits are phrasing content as long as all of
+// their children are phrasing content as well.
+func isPhrasingContent(n *html.Node) bool {
+	if n.Type == html.TextNode {
+		return true
+	}
+
+	if _, ok := phrasingElements[n.DataAtom]; ok {
+		return true
+	}
+
+	if n.DataAtom != atom.A && n.DataAtom != atom.Del && n.DataAtom != atom.Ins {
+		return false
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if !isPhrasingContent(c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isWhitespaceNode checks if the node is a <br> or a text that only has whitespace.
+func isWhitespaceNode(n *html.Node) bool {
+	if n.Type == html.TextNode {
+		return strings.TrimSpace(n.Data) == ""
+	}
+
+	return n.Type == html.ElementNode && n.DataAtom == atom.Br
+}
+
+// nextNode returns the node itself or its first next sibling which is not
+// a whitespace, i.e. element or non-empty text.
+func nextNode(n *html.Node) *html.Node {
+	for n != nil && n.Type != html.ElementNode && strings.TrimSpace(n.Data) == "" {
+		n = n.NextSibling
+	}
+
+	return n
+}
+
+// isSafeURL checks if the URL is safe to be used in the content. Relative URL
+// and URL with common scheme like http are safe, while the others like
+// javascript: are not. Data URL is only allowed for image, as long as it's
+// not a SVG, which can contain script as well.
+func isSafeURL(url string, isImage bool) bool {
+	url = removeURLControls(url)
+	if safeURLSchemes.MatchString(url) || !anyURLScheme.MatchString(url) {
+		return true
+	}
+
+	return isImage && dataImageURL.MatchString(url)
+}
+
+// removeURLControls removes whitespace and control characters from the URL.
+// Browsers ignore them inside scheme, e.g. "java\tscript:", so they must be
+// removed before the scheme is checked.
+func removeURLControls(url string) string {
+	return strings.Map(func(char rune) rune {
+		if char <= ' ' || char == 0x7f {
+			return -1
+		}
+		return char
+	}, url)
+}
+
+// srcsetCandidate is an image candidate in srcset. Width and density are
+// zero if the candidate doesn't have the descriptor.
+type srcsetCandidate struct {
+	url     string
+	width   float64
+	density float64
+}
+
+// parseSrcset parses the image candidates in srcset following the HTML spec.
+// The URL ends at whitespace, so commas inside it like in "w_300,h_200" are
+// kept, and only the trailing commas separate it from the next candidate.
+// Unknown descriptors are ignored.
+func parseSrcset(srcset string) []srcsetCandidate {
+	var candidates []srcsetCandidate
+	for {
+		srcset = strings.TrimLeft(srcset, " \t\n\r\f,")
+		if srcset == "" {
+			return candidates
+		}
+
+		end := strings.IndexAny(srcset, " \t\n\r\f")
+		if end < 0 {
+			end = len(srcset)
+		}
+
+		candidate := srcsetCandidate{url: srcset[:end]}
+		srcset = srcset[end:]
+
+		var descriptors string
+		if strings.HasSuffix(candidate.url, ",") {
+			candidate.url = strings.TrimRight(candidate.url, ",")
+		} else {
+			end = strings.IndexByte(srcset, ',')
+			if end < 0 {
+				end = len(srcset)
+			}
+			descriptors, srcset = srcset[:end], srcset[end:]
+		}
+
+		for _, descriptor := range strings.Fields(descriptors) {
+			value, err := strconv.ParseFloat(descriptor[:len(descriptor)-1], 64)
+			if err != nil || value <= 0 {
+				continue
+			}
+
+			switch descriptor[len(descriptor)-1] {
+			case 'w':
+				candidate.width = value
+			case 'x':
+				candidate.density = value
+			}
+		}
+
+		candidates = append(candidates, candidate)
+	}
+}
+
+// removeInvisibleChars removes the invisible characters that often injected
+// by CMS, i.e. zero width space, byte order mark, word joiner and soft hyphen.
+// Zero width joiner and non-joiner are kept, since they affect how the text
+// is rendered in some scripts and in emoji.
+func removeInvisibleChars(str string) string {
+	return strings.Map(func(char rune) rune {
+		switch char {
+		case '\u200B', '\uFEFF', '\u2060', '\u00AD', '\u180E':
+			return -1
+		}
+		return char
+	}, str)
+}
+
+// textSimilarity returns how similar the other text to the text, from 0 to 1.
+// It's measured by the length of words in the other text which also exist
+// in the text, so the word order and punctuation are ignored.
+func textSimilarity(text, other string) float64 {
+	isSeparator := func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}
+
+	words := make(map[string]struct{})
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), isSeparator) {
+		words[word] = struct{}{}
+	}
+
+	otherWords := strings.FieldsFunc(strings.ToLower(other), isSeparator)
+	if len(words) == 0 || len(otherWords) == 0 {
+		return 0
+	}
+
+	totalLength, uniqueLength := 0, 0
+	for _, word := range otherWords {
+		totalLength += StrLen(word)
+		if _, exist := words[word]; !exist {
+			uniqueLength += StrLen(word)
+		}
+	}
+
+	return 1 - float64(uniqueLength)/float64(totalLength)
+}
+
+// nameSuffixes is the suffixes that written after comma in a person's name,
+// e.g. "John Smith, Jr.", so they must not be split as another name.
+var nameSuffixes = map[string]struct{}{
+	"jr": {}, "sr": {}, "ii": {}, "iii": {}, "iv": {},
+	"phd": {}, "ph.d": {}, "md": {}, "m.d": {}, "esq": {},
+}
+
+// nameListSeparator matches the separators in a list of names, i.e. comma,
+// semicolon, ampersand and the word "and".
+var nameListSeparator = regexp.MustCompile(`(?i)\s*(?:[,;&]|\band\b)\s*`)
+
+// splitNames splits a list of names like "Jane Doe, John Smith and Bob" into
+// each name. Suffix like "Jr." is kept as part of the name before it, and
+// URL is not split since it's likely a link to the author's profile.
+func splitNames(str string) []string {
+	if strings.Contains(str, "://") {
+		return []string{str}
+	}
+
+	var names []string
+	for _, name := range nameListSeparator.Split(str, -1) {
+		name = strings.TrimSpace(name)
+		suffix := strings.TrimSuffix(strings.ToLower(name), ".")
+		if _, isSuffix := nameSuffixes[suffix]; isSuffix && len(names) > 0 {
+			names[len(names)-1] += ", " + name
+			continue
+		}
+
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// uniqueTexts normalizes each text, then removes the empty and the duplicate
+// ones. Texts are compared case-insensitively, and the first one is kept.
+func uniqueTexts(texts []string) []string {
+	var result []string
+	seen := make(map[string]struct{})
+	for _, text := range texts {
+		text = NormalizeText(text)
+		key := strings.ToLower(text)
+		if _, exist := seen[key]; exist || text == "" {
+			continue
+		}
+
+		seen[key] = struct{}{}
+		result = append(result, text)
+	}
+
+	return result
+}
+
+// isAncestorNode checks if the node is an ancestor of the other node.
+func isAncestorNode(n, other *html.Node) bool {
+	for p := other.Parent; p != nil; p = p.Parent {
+		if p == n {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cloneNode returns a deep copy of the node along with its descendants.
+func cloneNode(n *html.Node) *html.Node {
+	clone := &html.Node{
+		Type:      n.Type,
+		DataAtom:  n.DataAtom,
+		Data:      n.Data,
+		Namespace: n.Namespace,
+		Attr:      append([]html.Attribute(nil), n.Attr...),
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		clone.AppendChild(cloneNode(c))
+	}
+
+	return clone
+}
+
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+
+	text := ""
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		text += nodeText(c)
+	}
+
+	return text
+}
+
+func attrOr(n *html.Node, key string, defaultValue string) string {
+	for _, attr := range n.Attr {
+		if attr.Namespace == "" && attr.Key == key {
+			return attr.Val
+		}
+	}
+
+	return defaultValue
+}
+
+// isHiddenByStyle checks if the inline style declarations hide the element,
+// i.e. it contains display:none or visibility:hidden.
+func isHiddenByStyle(style string) bool {
+	for _, declaration := range strings.Split(style, ";") {
+		parts := strings.SplitN(declaration, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		property := strings.ToLower(strings.TrimSpace(parts[0]))
+		value := strings.ToLower(strings.TrimSpace(parts[1]))
+		value = strings.TrimSpace(strings.TrimSuffix(value, "!important"))
+
+		if (property == "display" && value == "none") ||
+			(property == "visibility" && value == "hidden") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,200 @@
+package readability
+
+import (
+	"github.com/PuerkitoBio/goquery"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStrLen(t *testing.T) {
+	tests := map[string]int{
+		"hello, world": 12,
+		"你好，世界":        5,
+		"こんにちは":        5,
+	}
+
+	for str, expected := range tests {
+		if length := StrLen(str); length != expected {
+			t.Errorf("StrLen(%q): expected %d, got %d", str, expected, length)
+		}
+	}
+}
+
+func TestCountCommas(t *testing.T) {
+	tests := map[string]int{
+		"one, two, three":    2,
+		"一，二，三":              2,
+		"واحد، اثنان، ثلاثة": 2,
+		"no comma here":      0,
+	}
+
+	for str, expected := range tests {
+		if count := countCommas(str); count != expected {
+			t.Errorf("countCommas(%q): expected %d, got %d", str, expected, count)
+		}
+	}
+}
+
+func TestScriptLanguage(t *testing.T) {
+	tests := map[string]string{
+		"我们今天去公园散步，天气很好。":                  "cmn",
+		"今日は公園を散歩しました。":                    "jpn",
+		"오늘은 공원에서 산책을 했습니다.":               "kor",
+		"วันนี้เราไปเดินเล่นที่สวนสาธารณะ": "tha",
+		"We went for a walk in the park.":  "",
+		"":                                 "",
+	}
+
+	for str, expected := range tests {
+		if lang := scriptLanguage(str); lang != expected {
+			t.Errorf("scriptLanguage(%q): expected %q, got %q", str, expected, lang)
+		}
+	}
+}
+
+func TestTextSimilarity(t *testing.T) {
+	tests := []struct {
+		text, other string
+		expected    float64
+	}{
+		{"Inside Amazon Go, a store of the future", "Inside Amazon Go: A Store of the Future", 1},
+		{"Inside Amazon Go", "How it works", 0},
+		{"Amazon Go store", "Amazon shop", 0.6},
+		{"", "How it works", 0},
+	}
+
+	for _, test := range tests {
+		if similarity := textSimilarity(test.text, test.other); similarity != test.expected {
+			t.Errorf("textSimilarity(%q, %q): expected %v, got %v", test.text, test.other, test.expected, similarity)
+		}
+	}
+}
+
+func TestSplitNames(t *testing.T) {
+	tests := map[string][]string{
+		"Jane Doe":                             {"Jane Doe"},
+		"Jane Doe, John Smith":                 {"Jane Doe", "John Smith"},
+		"Jane Doe, John Smith and Bob Jones":   {"Jane Doe", "John Smith", "Bob Jones"},
+		"Jane Doe & John Smith":                {"Jane Doe", "John Smith"},
+		"John Smith, Jr., Jane Doe":            {"John Smith, Jr.", "Jane Doe"},
+		"Jane Doe, PhD; Alexander Anderson":    {"Jane Doe, PhD", "Alexander Anderson"},
+		"https://www.example.com/a,b/jane-doe": {"https://www.example.com/a,b/jane-doe"},
+	}
+
+	for str, expected := range tests {
+		if names := splitNames(str); !reflect.DeepEqual(names, expected) {
+			t.Errorf("splitNames(%q): expected %q, got %q", str, expected, names)
+		}
+	}
+}
+
+func TestIsHiddenByStyle(t *testing.T) {
+	tests := map[string]bool{
+		"":                                  false,
+		"color: red":                        false,
+		"display:none":                      true,
+		"color: red; DISPLAY : None;":       true,
+		"visibility: hidden !important":     true,
+		"display: inline-block":             false,
+		"visibility: visible; margin: 0":    false,
+		"background: url(display:none.png)": false,
+	}
+
+	for style, expected := range tests {
+		if hidden := isHiddenByStyle(style); hidden != expected {
+			t.Errorf("isHiddenByStyle(%q) = %v, want %v", style, hidden, expected)
+		}
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		text      string
+		maxLength int
+		expected  string
+	}{
+		{"Amazon Go is a new kind of store.", 0, "Amazon Go is a new kind of store."},
+		{"Amazon Go is a new kind of store.", 50, "Amazon Go is a new kind of store."},
+		{"Amazon Go is a new kind of store.", 20, "Amazon Go is a new…"},
+		{"Amazon Go, a new kind of store.", 11, "Amazon Go…"},
+		{"亚马逊无人便利店开业了", 5, "亚马逊无人…"},
+	}
+
+	for _, test := range tests {
+		if result := truncateText(test.text, test.maxLength); result != test.expected {
+			t.Errorf("truncateText(%q, %d) = %q, want %q", test.text, test.maxLength, result, test.expected)
+		}
+	}
+}
+
+func TestIsSafeURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		isImage  bool
+		expected bool
+	}{
+		{"https://www.example.com/", false, true},
+		{"/images/store.jpg", true, true},
+		{"mailto:news@example.com", false, true},
+		{"javascript:alert(1)", false, false},
+		{" JAVA\tSCRIPT:alert(1)", false, false},
+		{"vbscript:msgbox(1)", false, false},
+		{"data:text/html;base64,PHNjcmlwdD4=", false, false},
+		{"data:image/png;base64,iVBORw0KGgo=", false, false},
+		{"data:image/png;base64,iVBORw0KGgo=", true, true},
+		{"data:image/svg+xml;base64,PHN2Zz4=", true, false},
+		{"data:image/bmp;base64,Qk0=", true, false},
+		{"data:image/png,%89PNG", true, false},
+	}
+
+	for _, test := range tests {
+		if safe := isSafeURL(test.url, test.isImage); safe != test.expected {
+			t.Errorf("isSafeURL(%q, %v) = %v, want %v", test.url, test.isImage, safe, test.expected)
+		}
+	}
+}
+
+func TestParseSrcset(t *testing.T) {
+	tests := map[string][]srcsetCandidate{
+		"/img/map.png, /img/map@2x.png 2x": {
+			{url: "/img/map.png"},
+			{url: "/img/map@2x.png", density: 2},
+		},
+		"/upload/w_300,h_200/a.jpg 300w,/upload/w_600,h_400/a.jpg 600w 400h": {
+			{url: "/upload/w_300,h_200/a.jpg", width: 300},
+			{url: "/upload/w_600,h_400/a.jpg", width: 600},
+		},
+		"/upload/c_scale,w_600/a.jpg,, /b.jpg 1.5x": {
+			{url: "/upload/c_scale,w_600/a.jpg"},
+			{url: "/b.jpg", density: 1.5},
+		},
+	}
+
+	for srcset, expected := range tests {
+		if candidates := parseSrcset(srcset); !reflect.DeepEqual(candidates, expected) {
+			t.Errorf("parseSrcset(%q): expected %v, got %v", srcset, expected, candidates)
+		}
+	}
+}
+
+func TestCloneNode(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<div class="post"><p>Amazon <b>Go</b></p></div>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clone := goquery.NewDocumentFromNode(cloneNode(doc.Get(0)))
+	clone.Find("b").Remove()
+	clone.Find("div").SetAttr("class", "story")
+
+	original, _ := doc.Find("body").Html()
+	if original != `<div class="post"><p>Amazon <b>Go</b></p></div>` {
+		t.Errorf("original node is modified: %q", original)
+	}
+
+	cloned, _ := clone.Find("body").Html()
+	if cloned != `<div class="story"><p>Amazon </p></div>` {
+		t.Errorf("unexpected cloned node: %q", cloned)
+	}
+}